@@ -0,0 +1,304 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"robert-tu.net/snippetbox/pkg/blob"
+	"robert-tu.net/snippetbox/pkg/forms"
+	"robert-tu.net/snippetbox/pkg/models"
+)
+
+// limits on uploaded attachments
+const (
+	// largest request body accepted by the upload form
+	maxUploadSize = 10 << 20
+	// uploaded parts above this size are buffered on disk while parsing
+	uploadMemory = 1 << 20
+	// time a request has to send its body, long enough for maxUploadSize on a slow link
+	uploadTimeout = 2 * time.Minute
+	// most attachments a snippet can have
+	maxAttachments = 10
+)
+
+// content types safe to show in the browser, everything else is downloaded
+var inlineTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+	"text/plain": true,
+}
+
+// attachmentsPage handler lists the attachments of a snippet with a form to upload more
+func (app *application) attachmentsPage(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	attachments, err := app.attachments.ForSnippet(s.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.render(w, r, "attachments.page.tmpl", &templateData{
+		Snippet:     s,
+		Attachments: attachments,
+		Form:        forms.New(nil),
+	})
+}
+
+// uploadAttachments handler saves the files of a multipart form as attachments
+// the body size is capped by limitBody before the CSRF check parses it
+func (app *application) uploadAttachments(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	err := r.ParseMultipartForm(uploadMemory)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	attachments, err := app.attachments.ForSnippet(s.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	files := r.MultipartForm.File["attachments"]
+	switch {
+	case len(files) == 0:
+		form.Errors.Add("attachments", "Choose at least one file")
+	case len(attachments)+len(files) > maxAttachments:
+		form.Errors.Add("attachments", fmt.Sprintf("A snippet can have at most %d attachments", maxAttachments))
+	}
+	for _, fh := range files {
+		if fh.Size == 0 {
+			form.Errors.Add("attachments", fmt.Sprintf("The file %q is empty", fh.Filename))
+		}
+	}
+
+	if !form.Valid() {
+		app.render(w, r, "attachments.page.tmpl", &templateData{
+			Snippet:     s,
+			Attachments: attachments,
+			Form:        form,
+		})
+		return
+	}
+
+	for _, fh := range files {
+		if err = app.saveAttachment(s.ID, fh); err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
+
+	app.session.Put(r, "flash", "Attachments uploaded successfully!")
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/%d/attachments", s.ID), http.StatusSeeOther)
+}
+
+// saveAttachment copies an uploaded file to the blob store and records it
+func (app *application) saveAttachment(snippetID int, fh *multipart.FileHeader) error {
+	f, err := fh.Open()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	contentType, err := attachmentType(fh.Filename, f)
+	if err != nil {
+		return err
+	}
+
+	key, err := newBlobKey()
+	if err != nil {
+		return err
+	}
+	if err = app.blobs.Put(key, f, fh.Size, contentType); err != nil {
+		return err
+	}
+
+	_, err = app.attachments.Insert(&models.Attachment{
+		SnippetID:   snippetID,
+		Name:        attachmentName(fh.Filename),
+		ContentType: contentType,
+		Size:        fh.Size,
+		Key:         key,
+	})
+	if err != nil {
+		// don't leave an unreachable blob behind
+		app.blobs.Delete(key)
+		return err
+	}
+	return nil
+}
+
+// showAttachment handler serves the content of an attachment
+// types that browsers could run as a page are only offered as downloads
+func (app *application) showAttachment(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.snippetFromRequest(w, r)
+	if !ok || !app.requireReadable(w, r, s) {
+		return
+	}
+
+	a, ok := app.attachmentFromRequest(w, r, s)
+	if !ok {
+		return
+	}
+
+	rc, err := app.blobs.Get(a.Key)
+	if err != nil {
+		if errors.Is(err, blob.ErrNotFound) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+	defer rc.Close()
+
+	disposition := "attachment"
+	if mediaType, _, _ := mime.ParseMediaType(a.ContentType); inlineTypes[mediaType] {
+		disposition = "inline"
+	}
+	if cd := mime.FormatMediaType(disposition, map[string]string{"filename": a.Name}); cd != "" {
+		disposition = cd
+	}
+
+	w.Header().Set("Content-Type", a.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(a.Size, 10))
+	w.Header().Set("Content-Disposition", disposition)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; sandbox")
+	noStore(w, s)
+	if _, err = io.Copy(w, rc); err != nil {
+		app.errorLog.Print(err)
+	}
+}
+
+// deleteAttachment handler removes an attachment from a snippet
+func (app *application) deleteAttachment(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	a, ok := app.attachmentFromRequest(w, r, s)
+	if !ok {
+		return
+	}
+
+	// the blob goes first so a failure never leaves one without a record
+	if err := app.blobs.Delete(a.Key); err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if err := app.attachments.Delete(a.ID); err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.session.Put(r, "flash", "Attachment deleted successfully!")
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/%d/attachments", s.ID), http.StatusSeeOther)
+}
+
+// attachmentFromRequest loads the attachment of s named by the :aid parameter
+func (app *application) attachmentFromRequest(w http.ResponseWriter, r *http.Request, s *models.Snippet) (*models.Attachment, bool) {
+	id, err := strconv.Atoi(r.URL.Query().Get(":aid"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return nil, false
+	}
+
+	a, err := app.attachments.Get(s.ID, id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return nil, false
+	}
+	return a, true
+}
+
+// purgeAttachments removes the blobs and records of up to limit attachments left behind by purged snippets
+func (app *application) purgeAttachments(limit int) (int, error) {
+	orphans, err := app.attachments.Orphans(limit)
+	if err != nil {
+		return 0, err
+	}
+	for i, a := range orphans {
+		if err = app.blobs.Delete(a.Key); err != nil {
+			return i, err
+		}
+		if err = app.attachments.Delete(a.ID); err != nil {
+			return i, err
+		}
+	}
+	return len(orphans), nil
+}
+
+// attachmentType picks the content type of an upload from its first bytes
+// the extension is only trusted when sniffing finds nothing more specific
+func attachmentType(name string, f multipart.File) (string, error) {
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	if _, err = f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	sniffed := http.DetectContentType(head[:n])
+	if sniffed == "application/octet-stream" || strings.HasPrefix(sniffed, "text/plain") {
+		if byName := mime.TypeByExtension(strings.ToLower(path.Ext(name))); byName != "" {
+			return byName, nil
+		}
+	}
+	return sniffed, nil
+}
+
+// attachmentName strips the directories some browsers send with a file name
+func attachmentName(name string) string {
+	name = strings.TrimSpace(path.Base(strings.ReplaceAll(name, `\`, "/")))
+	if name == "." || name == "/" || name == "" {
+		return "attachment"
+	}
+	for utf8.RuneCountInString(name) > 255 {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	return name
+}
+
+// newBlobKey returns a random key for an attachment
+func newBlobKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "attachments/" + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// seekableFile adapts a strings.Reader to multipart.File
+type seekableFile struct {
+	*strings.Reader
+}
+
+func (f seekableFile) Close() error { return nil }
+
+func TestAttachmentType(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"frog.png", "\x89PNG\r\n\x1a\n", "image/png"},
+		{"notes", "everything is fine\n", "text/plain; charset=utf-8"},
+		{"data.json", `{"a": 1}`, "application/json"},
+		{"report.PDF", "plain words", "application/pdf"},
+		{"innocent.png", "<html><script>alert(1)</script>", "text/html; charset=utf-8"},
+		{"blob", "\x00\x01\x02", "application/octet-stream"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := seekableFile{strings.NewReader(tt.content)}
+			got, err := attachmentType(tt.name, f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("want %q; got %q", tt.want, got)
+			}
+
+			// the file is rewound for uploading
+			if f.Len() != len(tt.content) {
+				t.Errorf("want file to be rewound")
+			}
+		})
+	}
+}
+
+func TestAttachmentName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"frog.png", "frog.png"},
+		{`C:\Users\alice\frog.png`, "frog.png"},
+		{"../../etc/passwd", "passwd"},
+		{"", "attachment"},
+		{strings.Repeat("é", 300), strings.Repeat("é", 255)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := attachmentName(tt.name); got != tt.want {
+				t.Errorf("want %q; got %q", tt.want, got)
+			}
+		})
+	}
+}
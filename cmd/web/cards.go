@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"html"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"robert-tu.net/snippetbox/pkg/blob"
+	"robert-tu.net/snippetbox/pkg/card"
+	"robert-tu.net/snippetbox/pkg/highlight"
+	"robert-tu.net/snippetbox/pkg/markdown"
+	"robert-tu.net/snippetbox/pkg/models"
+)
+
+// longest og:description, in characters
+const maxDescription = 200
+
+// define pageMeta type for the link preview tags of a page
+type pageMeta struct {
+	Title       string
+	Description string
+	// absolute addresses of the page and of its preview image
+	URL   string
+	Image string
+}
+
+// snippetSubtitle describes a snippet in a line, like "main.go · Go · by Alice"
+func snippetSubtitle(s *models.Snippet) string {
+	parts := []string{}
+	if s.Filename != "" {
+		parts = append(parts, s.Filename)
+	}
+	if l, ok := highlight.Lookup(s.Language); ok {
+		parts = append(parts, l.Label)
+	}
+	parts = append(parts, "by "+s.Owner)
+	return strings.Join(parts, " · ")
+}
+
+// snippetCard returns the preview card of a snippet
+func snippetCard(s *models.Snippet) card.Card {
+	c := card.Card{
+		Title:    s.Title,
+		Subtitle: snippetSubtitle(s),
+		Code:     shareableContent(s),
+	}
+	switch {
+	case s.Protected:
+		c.Notice = "This snippet is protected by a passphrase."
+	case s.ViewLimit:
+		c.Notice = "This snippet can only be viewed a few times."
+	}
+	return c
+}
+
+// tagRX matches the tags of sanitized HTML
+var tagRX = regexp.MustCompile(`<[^>]*>`)
+
+// snippetMeta returns the link preview tags of a snippet page
+func (app *application) snippetMeta(r *http.Request, s *models.Snippet) *pageMeta {
+	content := shareableContent(s)
+	// markdown is described by its sanitized text rather than its markup
+	if s.Language == highlight.Markdown && content != "" {
+		rendered, err := markdown.Render(content)
+		if err != nil {
+			rendered = ""
+		}
+		content = html.UnescapeString(tagRX.ReplaceAllString(string(rendered), " "))
+	}
+
+	description := strings.Join(strings.Fields(content), " ")
+	if description == "" {
+		description = snippetSubtitle(s)
+	}
+	if runes := []rune(description); len(runes) > maxDescription {
+		description = string(runes[:maxDescription-1]) + "…"
+	}
+
+	return &pageMeta{
+		Title:       s.Title,
+		Description: description,
+		URL:         app.absoluteURL(r, snippetURL(s)),
+		Image:       app.absoluteURL(r, snippetURL(s)+"/card.png"),
+	}
+}
+
+// cardImage handler returns the preview image of a snippet, drawing it on the first request
+func (app *application) cardImage(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.snippetFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	c := snippetCard(s)
+	img, err := app.cachedImage("cards/"+c.Key()+".png", app.limitRender(func() ([]byte, error) {
+		return card.Render(c)
+	}))
+	if err != nil {
+		app.renderError(w, err)
+		return
+	}
+
+	serveImage(w, r, "image/png", c.Key(), s, img)
+}
+
+// errBusy is returned by limitRender when every render slot is taken
+var errBusy = errors.New("all render slots are busy")
+
+// limitRender wraps draw so it runs in one of the render slots, failing with errBusy if none is free
+func (app *application) limitRender(draw func() ([]byte, error)) func() ([]byte, error) {
+	return func() ([]byte, error) {
+		select {
+		case app.renders <- struct{}{}:
+			defer func() { <-app.renders }()
+			return draw()
+		default:
+			return nil, errBusy
+		}
+	}
+}
+
+// renderError answers a failed render, asking the client to retry when the slots were busy
+func (app *application) renderError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errBusy) {
+		w.Header().Set("Retry-After", "1")
+		app.clientError(w, http.StatusServiceUnavailable)
+		return
+	}
+	app.serverError(w, err)
+}
+
+// cachedImage loads an image from the cache, or draws and stores it if it isn't there
+// images are still returned when they can't be stored
+func (app *application) cachedImage(key string, draw func() ([]byte, error)) ([]byte, error) {
+	rc, err := app.cache.Get(key)
+	if err == nil {
+		defer rc.Close()
+		return io.ReadAll(rc)
+	}
+	if !errors.Is(err, blob.ErrNotFound) {
+		return nil, err
+	}
+
+	img, err := draw()
+	if err != nil {
+		return nil, err
+	}
+	err = app.cache.Put(key, bytes.NewReader(img), int64(len(img)), "")
+	if err != nil {
+		app.errorLog.Printf("caching %s: %v", key, err)
+	}
+	return img, nil
+}
+
+// serveImage writes an image identified by hash, answering conditional requests
+func serveImage(w http.ResponseWriter, r *http.Request, contentType, hash string, s *models.Snippet, img []byte) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("ETag", `"`+hash[:32]+`"`)
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	noStore(w, s)
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(img))
+}
+
+// codeImage returns a handler drawing the code of a snippet in a window, as a png or svg image
+// ?theme=, ?padding= and ?width= style the image and ?lines= picks a range of lines
+func (app *application) codeImage(format string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s, ok := app.snippetFromRequest(w, r)
+		if !ok || !app.requireReadable(w, r, s) {
+			return
+		}
+
+		c := card.Code{
+			Title:    s.Title,
+			Code:     s.Content,
+			Language: s.Language,
+			Theme:    r.URL.Query().Get("theme"),
+		}
+		if s.Filename != "" {
+			c.Title = s.Filename
+		}
+		if c.Theme == "" {
+			c.Theme = card.Themes[0]
+		}
+		if !card.ValidTheme(c.Theme) {
+			app.clientError(w, http.StatusBadRequest)
+			return
+		}
+
+		var err error
+		c.Padding, err = queryInt(r, "padding", card.DefaultPadding)
+		if err != nil || c.Padding < 0 || c.Padding > card.MaxPadding {
+			app.clientError(w, http.StatusBadRequest)
+			return
+		}
+		c.Width, err = queryInt(r, "width", 0)
+		if err != nil || (c.Width != 0 && (c.Width < card.MinWidth || c.Width > card.MaxWidth)) {
+			app.clientError(w, http.StatusBadRequest)
+			return
+		}
+
+		selection := r.URL.Query().Get("lines")
+		if selection != "" {
+			first, last := parseLineRange(selection)
+			lines := strings.Split(c.Code, "\n")
+			if first == 0 || first > len(lines) {
+				app.clientError(w, http.StatusBadRequest)
+				return
+			}
+			if last > len(lines) {
+				last = len(lines)
+			}
+			c.Code = strings.Join(lines[first-1:last], "\n")
+		}
+
+		draw := app.limitRender(func() ([]byte, error) {
+			if format == "svg" {
+				return card.RenderSVG(c)
+			}
+			return card.RenderPNG(c)
+		})
+
+		// only the default look is cached so query strings can't fill the disk
+		// every image is drawn in a render slot, cached or not
+		key := c.Key(format)
+		var img []byte
+		if c.Theme == card.Themes[0] && c.Padding == card.DefaultPadding && c.Width == 0 && selection == "" {
+			img, err = app.cachedImage("code/"+key+"."+format, draw)
+		} else {
+			img, err = draw()
+		}
+		if err != nil {
+			app.renderError(w, err)
+			return
+		}
+
+		contentType := "image/png"
+		if format == "svg" {
+			contentType = "image/svg+xml"
+			// svg opened on its own is a document, it has no scripts but shouldn't run any
+			w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'")
+		}
+		serveImage(w, r, contentType, key, s, img)
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"robert-tu.net/snippetbox/pkg/forms"
+	"robert-tu.net/snippetbox/pkg/models"
+)
+
+// longest comment accepted, in characters
+const maxCommentLength = 2000
+
+// replies nested deeper than this are indented no further
+const maxCommentIndent = 4
+
+// define commentView type for a comment placed in its thread
+type commentView struct {
+	*models.Comment
+	// indent level, capped at maxCommentIndent
+	Depth int
+	// set when the user may edit or delete the comment
+	CanModify bool
+}
+
+// threadComments orders comments depth first so every reply follows its parent
+// userID may modify their own comments and, as the snippet owner, every comment
+func threadComments(comments []*models.Comment, userID, ownerID int) []commentView {
+	ids := map[int]bool{}
+	for _, c := range comments {
+		ids[c.ID] = true
+	}
+	// replies of each comment, roots are under 0
+	replies := map[int][]*models.Comment{}
+	for _, c := range comments {
+		parent := c.ParentID
+		if !ids[parent] {
+			parent = 0
+		}
+		replies[parent] = append(replies[parent], c)
+	}
+
+	var thread []commentView
+	var walk func(parent, depth int)
+	walk = func(parent, depth int) {
+		for _, c := range replies[parent] {
+			indent := depth
+			if indent > maxCommentIndent {
+				indent = maxCommentIndent
+			}
+			thread = append(thread, commentView{
+				Comment:   c,
+				Depth:     indent,
+				CanModify: !c.Deleted && userID != 0 && (userID == c.UserID || userID == ownerID),
+			})
+			walk(c.ID, depth+1)
+		}
+	}
+	walk(0, 0)
+	return thread
+}
+
+// createComment handler adds a comment, or a reply to one, under a snippet
+func (app *application) createComment(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.snippetFromRequest(w, r)
+	if !ok || !app.requireReadable(w, r, s) {
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Require("content")
+	form.MaxLength("content", maxCommentLength)
+
+	parent, ok := app.replyTo(w, form, s)
+	if !ok {
+		return
+	}
+
+	if !form.Valid() {
+		app.render(w, r, "comment.page.tmpl", &templateData{
+			Snippet:   s,
+			Permalink: snippetURL(s),
+			ReplyTo:   parent,
+			Form:      form,
+		})
+		return
+	}
+
+	parentID := 0
+	if parent != nil {
+		parentID = parent.ID
+	}
+	id, err := app.comments.Insert(s.ID, parentID, app.authenticatedUserID(r), form.Get("content"))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.session.Put(r, "flash", "Comment posted!")
+
+	http.Redirect(w, r, fmt.Sprintf("%s#comment-%d", snippetURL(s), id), http.StatusSeeOther)
+}
+
+// replyTo loads the comment named by the parent field, which must be on snippet s
+// returns nil for a new thread
+func (app *application) replyTo(w http.ResponseWriter, form *forms.Form, s *models.Snippet) (*models.Comment, bool) {
+	if form.Get("parent") == "" {
+		return nil, true
+	}
+
+	id, err := strconv.Atoi(form.Get("parent"))
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return nil, false
+	}
+
+	parent, err := app.comments.Get(id)
+	if err != nil && !errors.Is(err, models.ErrNoRecord) {
+		app.serverError(w, err)
+		return nil, false
+	}
+	if err != nil || parent.SnippetID != s.ID || parent.Deleted {
+		app.clientError(w, http.StatusBadRequest)
+		return nil, false
+	}
+	return parent, true
+}
+
+// editCommentForm handler shows a comment to its author or the snippet owner for editing
+func (app *application) editCommentForm(w http.ResponseWriter, r *http.Request) {
+	c, s, ok := app.modifiableComment(w, r)
+	if !ok {
+		return
+	}
+
+	app.render(w, r, "comment.page.tmpl", &templateData{
+		Snippet:   s,
+		Permalink: snippetURL(s),
+		Comment:   c,
+		Form:      forms.New(url.Values{"content": {c.Content}}),
+	})
+}
+
+// editComment handler saves the new content of a comment
+func (app *application) editComment(w http.ResponseWriter, r *http.Request) {
+	c, s, ok := app.modifiableComment(w, r)
+	if !ok {
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Require("content")
+	form.MaxLength("content", maxCommentLength)
+
+	if !form.Valid() {
+		app.render(w, r, "comment.page.tmpl", &templateData{
+			Snippet:   s,
+			Permalink: snippetURL(s),
+			Comment:   c,
+			Form:      form,
+		})
+		return
+	}
+
+	err = app.comments.Update(c.ID, form.Get("content"))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.session.Put(r, "flash", "Comment updated!")
+
+	http.Redirect(w, r, fmt.Sprintf("%s#comment-%d", snippetURL(s), c.ID), http.StatusSeeOther)
+}
+
+// deleteComment handler removes a comment
+func (app *application) deleteComment(w http.ResponseWriter, r *http.Request) {
+	c, s, ok := app.modifiableComment(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.comments.Delete(c.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.session.Put(r, "flash", "Comment deleted.")
+
+	http.Redirect(w, r, snippetURL(s)+"#comments", http.StatusSeeOther)
+}
+
+// modifiableComment loads the comment named by the :id parameter and its snippet
+// only the author of the comment and the owner of the snippet may change it
+func (app *application) modifiableComment(w http.ResponseWriter, r *http.Request) (*models.Comment, *models.Snippet, bool) {
+	id, err := strconv.Atoi(r.URL.Query().Get(":id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return nil, nil, false
+	}
+
+	c, err := app.comments.Get(id)
+	if err == nil && c.Deleted {
+		err = models.ErrNoRecord
+	}
+	var s *models.Snippet
+	if err == nil {
+		s, err = app.snippets.Get(c.SnippetID)
+	}
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return nil, nil, false
+	}
+
+	// comment authors found an unlisted snippet by its slug, but lose it once it is made private
+	userID := app.authenticatedUserID(r)
+	if !app.canView(r, s, userID == c.UserID) {
+		app.notFound(w)
+		return nil, nil, false
+	}
+
+	if userID != c.UserID && userID != s.UserID {
+		app.clientError(w, http.StatusForbidden)
+		return nil, nil, false
+	}
+	return c, s, true
+}
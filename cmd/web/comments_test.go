@@ -0,0 +1,59 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"robert-tu.net/snippetbox/pkg/models"
+)
+
+func TestThreadComments(t *testing.T) {
+	comments := []*models.Comment{
+		{ID: 1, UserID: 2},
+		{ID: 2, UserID: 3},
+		{ID: 3, ParentID: 1, UserID: 1},
+		{ID: 4, ParentID: 3, UserID: 2, Deleted: true},
+		{ID: 5, ParentID: 2, UserID: 2},
+		{ID: 6, ParentID: 4, UserID: 3},
+		// parent was removed
+		{ID: 7, ParentID: 99, UserID: 3},
+	}
+
+	// user 2 viewing a snippet owned by user 3
+	thread := threadComments(comments, 2, 3)
+
+	var ids, depths []int
+	var modify []bool
+	for _, c := range thread {
+		ids = append(ids, c.ID)
+		depths = append(depths, c.Depth)
+		modify = append(modify, c.CanModify)
+	}
+
+	if want := []int{1, 3, 4, 6, 2, 5, 7}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("want order %v; got %v", want, ids)
+	}
+	if want := []int{0, 1, 2, 3, 0, 1, 0}; !reflect.DeepEqual(depths, want) {
+		t.Errorf("want depths %v; got %v", want, depths)
+	}
+	if want := []bool{true, false, false, false, false, true, false}; !reflect.DeepEqual(modify, want) {
+		t.Errorf("want CanModify %v; got %v", want, modify)
+	}
+
+	// the snippet owner may modify every comment that isn't deleted
+	for _, c := range threadComments(comments, 3, 3) {
+		if c.CanModify == c.Deleted {
+			t.Errorf("want owner CanModify %t for comment %d", !c.Deleted, c.ID)
+		}
+	}
+
+	// deep replies stop indenting
+	var deep []*models.Comment
+	for i := 1; i <= maxCommentIndent+3; i++ {
+		deep = append(deep, &models.Comment{ID: i, ParentID: i - 1})
+	}
+	thread = threadComments(deep, 0, 1)
+	if got := thread[len(thread)-1].Depth; got != maxCommentIndent {
+		t.Errorf("want depth capped at %d; got %d", maxCommentIndent, got)
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"robert-tu.net/snippetbox/pkg/highlight"
+	"robert-tu.net/snippetbox/pkg/markdown"
+	"robert-tu.net/snippetbox/pkg/models"
+)
+
+// size of the frame suggested to oEmbed consumers, in pixels
+const (
+	embedWidth     = 640
+	embedMaxHeight = 480
+	// heights of the title bar and of one line of code
+	embedHeaderHeight = 60
+	embedLineHeight   = 20
+)
+
+// permalinkRX matches the paths of snippet pages accepted by the oEmbed endpoint
+var permalinkRX = regexp.MustCompile(`^/(?:snippet/([0-9]+)|s/([A-Za-z0-9_-]+))/?$`)
+
+// embeddable reports whether a snippet may be shown on other sites
+// passphrases and view limits can't be honoured inside a frame, so those snippets stay on snippetbox
+func embeddable(s *models.Snippet) bool {
+	return s.Visibility != models.Private && !s.Protected && !s.ViewLimit
+}
+
+// embedHeight estimates the height of the embedded snippet, up to embedMaxHeight
+func embedHeight(s *models.Snippet) int {
+	h := embedHeaderHeight + embedLineHeight*(strings.Count(s.Content, "\n")+1)
+	if h > embedMaxHeight {
+		h = embedMaxHeight
+	}
+	return h
+}
+
+// embeddedSnippet helper fetches the snippet of an embed route
+// embeds are served without a session, so they show what anyone following the link would see
+func (app *application) embeddedSnippet(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	s, ok := app.snippetFromRequest(w, r)
+	if !ok {
+		return nil, false
+	}
+	if !embeddable(s) {
+		app.clientError(w, http.StatusForbidden)
+		return nil, false
+	}
+	return s, true
+}
+
+// embedSnippet handler shows a snippet on its own, for an iframe on another site
+func (app *application) embedSnippet(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.embeddedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	td := &templateData{
+		Snippet:   s,
+		Permalink: app.absoluteURL(r, snippetURL(s)),
+	}
+
+	// markdown is rendered unless the source was asked for
+	if s.Language == highlight.Markdown && r.URL.Query().Get("view") != "source" {
+		rendered, err := markdown.Render(s.Content)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		td.Rendered = rendered
+	} else {
+		lines, err := highlight.Lines(s.Content, s.Language)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		first, last := parseLineRange(r.URL.Query().Get("lines"))
+		td.Lines = newCodeLines(lines, first, last)
+	}
+
+	noStore(w, s)
+	app.renderTemplate(w, "embed.page.tmpl", td)
+}
+
+// embedScript handler returns a script that puts the embedded snippet in place of its script tag
+func (app *application) embedScript(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.embeddedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	src := app.absoluteURL(r, snippetURL(s)+"/embed")
+	if lines := r.URL.Query().Get("lines"); lines != "" {
+		src += "?lines=" + url.QueryEscape(lines)
+	}
+	// json strings are valid javascript with <, > and & escaped
+	srcJSON, _ := json.Marshal(src)
+	titleJSON, _ := json.Marshal(s.Title)
+
+	w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+	noStore(w, s)
+	fmt.Fprintf(w, `(function () {
+	var script = document.currentScript;
+	var frame = document.createElement("iframe");
+	frame.src = %s;
+	frame.title = %s;
+	frame.height = "%d";
+	frame.style.width = "100%%";
+	frame.style.border = "0";
+	script.parentNode.insertBefore(frame, script.nextSibling);
+	// the embedded page reports its full height once loaded
+	window.addEventListener("message", function (e) {
+		if (e.source === frame.contentWindow && e.data && e.data.snippetboxHeight) {
+			frame.height = String(e.data.snippetboxHeight);
+		}
+	});
+})();
+`, srcJSON, titleJSON, embedHeight(s))
+}
+
+// oembedResponse is the answer to an oEmbed request, see https://oembed.com
+type oembedResponse struct {
+	Type         string `json:"type"`
+	Version      string `json:"version"`
+	Title        string `json:"title"`
+	AuthorName   string `json:"author_name,omitempty"`
+	ProviderName string `json:"provider_name"`
+	ProviderURL  string `json:"provider_url"`
+	HTML         string `json:"html"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+}
+
+// oembed handler describes how to embed the snippet linked by ?url=
+// only JSON is supported, other formats get the 501 the specification asks for
+func (app *application) oembed(w http.ResponseWriter, r *http.Request) {
+	if format := r.URL.Query().Get("format"); format != "" && format != "json" {
+		app.clientError(w, http.StatusNotImplemented)
+		return
+	}
+
+	maxWidth, err := queryInt(r, "maxwidth", 0)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	maxHeight, err := queryInt(r, "maxheight", 0)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	// the link must point at a snippet page of this site
+	u, err := url.Parse(r.URL.Query().Get("url"))
+	site, _ := url.Parse(app.absoluteURL(r, "/"))
+	if err != nil || u.Host != site.Host {
+		app.notFound(w)
+		return
+	}
+	m := permalinkRX.FindStringSubmatch(u.Path)
+	if m == nil {
+		app.notFound(w)
+		return
+	}
+
+	var s *models.Snippet
+	bySlug := m[2] != ""
+	if bySlug {
+		s, err = app.snippets.GetBySlug(m[2])
+	} else {
+		id, _ := strconv.Atoi(m[1])
+		s, err = app.snippets.Get(id)
+	}
+	s, ok := app.visibleSnippet(w, r, s, err, bySlug)
+	if !ok {
+		return
+	}
+	// the specification answers 401 for resources that can't be embedded
+	if !embeddable(s) {
+		app.clientError(w, http.StatusUnauthorized)
+		return
+	}
+
+	width, height := embedWidth, embedHeight(s)
+	if maxWidth > 0 && width > maxWidth {
+		width = maxWidth
+	}
+	if maxHeight > 0 && height > maxHeight {
+		height = maxHeight
+	}
+
+	src := app.absoluteURL(r, snippetURL(s)+"/embed")
+	rs := oembedResponse{
+		Type:         "rich",
+		Version:      "1.0",
+		Title:        s.Title,
+		AuthorName:   s.Owner,
+		ProviderName: "Snippetbox",
+		ProviderURL:  app.absoluteURL(r, "/"),
+		HTML: fmt.Sprintf(`<iframe src="%s" width="%d" height="%d" title="%s" style="border: 0"></iframe>`,
+			html.EscapeString(src), width, height, html.EscapeString(s.Title)),
+		Width:  width,
+		Height: height,
+	}
+
+	js, err := json.Marshal(rs)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	noStore(w, s)
+	w.Write(js)
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"robert-tu.net/snippetbox/pkg/forms"
+)
+
+// special values of the expires field
+const (
+	expiresCustom = "custom"
+	expiresNever  = "never"
+)
+
+// furthest a custom expiry date can be set
+const maxCustomExpiry = 10 * 365 * 24 * time.Hour
+
+// define expiryPreset type for one of the expiry choices offered in forms
+type expiryPreset struct {
+	Value    string
+	Label    string
+	Duration time.Duration
+}
+
+// parseExpiryPresets reads a comma separated list of durations like "1h,1d,2w"
+// d and w are days and weeks, other units are parsed by time.ParseDuration
+func parseExpiryPresets(s string) ([]expiryPreset, error) {
+	var presets []expiryPreset
+	for _, value := range strings.Split(s, ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		d, err := parseDuration(value)
+		if err != nil {
+			return nil, err
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("expiry preset %q must be positive", value)
+		}
+		presets = append(presets, expiryPreset{Value: value, Label: durationLabel(d), Duration: d})
+	}
+	if len(presets) == 0 {
+		return nil, fmt.Errorf("no expiry presets in %q", s)
+	}
+	return presets, nil
+}
+
+// parseDuration extends time.ParseDuration with whole days and weeks
+func parseDuration(s string) (time.Duration, error) {
+	units := map[byte]time.Duration{'d': 24 * time.Hour, 'w': 7 * 24 * time.Hour}
+	if unit, ok := units[s[len(s)-1]]; ok {
+		n, err := strconv.Atoi(s[:len(s)-1])
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(n) * unit, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// durationLabel describes a duration in the largest unit that divides it
+func durationLabel(d time.Duration) string {
+	day := 24 * time.Hour
+	units := []struct {
+		name string
+		size time.Duration
+	}{
+		{"year", 365 * day},
+		{"week", 7 * day},
+		{"day", day},
+		{"hour", time.Hour},
+		{"minute", time.Minute},
+	}
+	for _, u := range units {
+		if d%u.size == 0 {
+			n := int(d / u.size)
+			if n == 1 {
+				return "1 " + u.name
+			}
+			return fmt.Sprintf("%d %ss", n, u.name)
+		}
+	}
+	return d.String()
+}
+
+// validExpiry checks the expires field of a form and returns the chosen expiry time
+// a zero time means the snippet never expires
+func (app *application) validExpiry(r *http.Request, form *forms.Form) time.Time {
+	now := time.Now().UTC()
+	switch value := form.Get("expires"); value {
+	case "":
+		return time.Time{}
+	case expiresNever:
+		// only offered to authenticated users
+		if !app.isAuthenticated(r) {
+			form.Errors.Add("expires", "This field is invalid")
+		}
+		return time.Time{}
+	case expiresCustom:
+		on, err := time.Parse("2006-01-02", form.Get("expires_on"))
+		if err != nil {
+			form.Errors.Add("expires_on", "This field must be a date")
+			return time.Time{}
+		}
+		// expire at the end of the chosen day
+		expires := on.Add(24 * time.Hour)
+		switch {
+		case !expires.After(now):
+			form.Errors.Add("expires_on", "This date must be in the future")
+		case expires.Sub(now) > maxCustomExpiry:
+			form.Errors.Add("expires_on", "This date is too far in the future")
+		}
+		return expires
+	default:
+		for _, p := range app.expiryPresets {
+			if p.Value == value {
+				return now.Add(p.Duration)
+			}
+		}
+		form.Errors.Add("expires", "This field is invalid")
+		return time.Time{}
+	}
+}
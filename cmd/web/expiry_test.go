@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestParseExpiryPresets(t *testing.T) {
+	tests := []struct {
+		name      string
+		presets   string
+		wantLabel []string
+		wantErr   bool
+	}{
+		{"Defaults", "1d,1w,365d", []string{"1 day", "1 week", "1 year"}, false},
+		{"Go durations", "90m, 12h", []string{"90 minutes", "12 hours"}, false},
+		{"Plural days", "30d", []string{"30 days"}, false},
+		{"Invalid unit", "3x", nil, true},
+		{"Invalid days", "ad", nil, true},
+		{"Negative", "-1d", nil, true},
+		{"Empty", " , ", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			presets, err := parseExpiryPresets(tt.presets)
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("want error %t; got %v", tt.wantErr, err)
+			}
+
+			if len(presets) != len(tt.wantLabel) {
+				t.Fatalf("want %d presets; got %d", len(tt.wantLabel), len(presets))
+			}
+			for i, p := range presets {
+				if p.Label != tt.wantLabel[i] {
+					t.Errorf("want label %q; got %q", tt.wantLabel[i], p.Label)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"robert-tu.net/snippetbox/pkg/models"
+)
+
+// snippets in a feed
+const feedSize = 20
+
+// define feed type for the snippets of a feed, whatever its format
+type feed struct {
+	Title string
+	// absolute address of the page the feed follows
+	Link string
+	// absolute address of the feed itself
+	Self     string
+	Snippets []*models.Snippet
+}
+
+// updated returns when the newest snippet of the feed was created, zero for an empty feed
+func (f *feed) updated() time.Time {
+	var t time.Time
+	for _, s := range f.Snippets {
+		if s.Created.After(t) {
+			t = s.Created
+		}
+	}
+	return t.UTC()
+}
+
+// feedFromRequest loads the latest public snippets, those tagged :name or by user :id when set
+func (app *application) feedFromRequest(w http.ResponseWriter, r *http.Request) (*feed, bool) {
+	f := &feed{
+		Title: "Snippetbox",
+		Link:  app.absoluteURL(r, "/"),
+		Self:  app.absoluteURL(r, r.URL.Path),
+	}
+
+	tag := r.URL.Query().Get(":name")
+	userID := 0
+	switch {
+	case tag != "":
+		f.Title = "Snippets tagged " + tag + " - Snippetbox"
+		// pat unescapes parameters as query strings
+		f.Link = app.absoluteURL(r, "/tag/"+url.QueryEscape(tag))
+	case r.URL.Query().Get(":id") != "":
+		id, err := strconv.Atoi(r.URL.Query().Get(":id"))
+		if err != nil || id < 1 {
+			app.notFound(w)
+			return nil, false
+		}
+		u, err := app.users.Get(id)
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				app.notFound(w)
+			} else {
+				app.serverError(w, err)
+			}
+			return nil, false
+		}
+		userID = u.ID
+		f.Title = "Snippets by " + u.Name + " - Snippetbox"
+	}
+
+	var err error
+	f.Snippets, err = app.snippets.Recent(tag, userID, feedSize)
+	if err != nil {
+		app.serverError(w, err)
+		return nil, false
+	}
+	return f, true
+}
+
+// define atom types, see RFC 4287
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Links   []atomLink  `xml:"link"`
+	Updated string      `xml:"updated"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntry struct {
+	Title     string     `xml:"title"`
+	ID        string     `xml:"id"`
+	Link      atomLink   `xml:"link"`
+	Published string     `xml:"published"`
+	Updated   string     `xml:"updated"`
+	Author    atomPerson `xml:"author"`
+	Content   *atomText  `xml:"content"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// atomFeed handler returns the feed of the request as Atom
+func (app *application) atomFeed(w http.ResponseWriter, r *http.Request) {
+	f, ok := app.feedFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	// an empty feed still needs a date
+	updated := f.updated()
+	if updated.IsZero() {
+		updated = time.Unix(0, 0).UTC()
+	}
+
+	doc := atomFeed{
+		Title: f.Title,
+		ID:    f.Self,
+		Links: []atomLink{
+			{Href: f.Self, Rel: "self", Type: "application/atom+xml"},
+			{Href: f.Link, Rel: "alternate", Type: "text/html"},
+		},
+		Updated: updated.Format(time.RFC3339),
+	}
+	for _, s := range f.Snippets {
+		link := app.absoluteURL(r, snippetURL(s))
+		// snippets only record when they were created
+		created := s.Created.UTC().Format(time.RFC3339)
+		entry := atomEntry{
+			Title:     s.Title,
+			ID:        link,
+			Link:      atomLink{Href: link, Rel: "alternate", Type: "text/html"},
+			Published: created,
+			Updated:   created,
+			Author:    atomPerson{Name: s.Owner},
+		}
+		if content := shareableContent(s); content != "" {
+			entry.Content = &atomText{Type: "text", Body: content}
+		}
+		doc.Entries = append(doc.Entries, entry)
+	}
+
+	app.serveFeed(w, r, "application/atom+xml; charset=utf-8", doc)
+}
+
+// define rss types, see https://www.rssboard.org/rss-specification
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+	Description string  `xml:"description,omitempty"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// rssFeed handler returns the feed of the request as RSS 2.0
+func (app *application) rssFeed(w http.ResponseWriter, r *http.Request) {
+	f, ok := app.feedFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	doc := rssFeed{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:       f.Title,
+			Link:        f.Link,
+			Description: "The latest snippets on Snippetbox",
+		},
+	}
+	if updated := f.updated(); !updated.IsZero() {
+		doc.Channel.LastBuildDate = updated.Format(time.RFC1123Z)
+	}
+	for _, s := range f.Snippets {
+		link := app.absoluteURL(r, snippetURL(s))
+		doc.Channel.Items = append(doc.Channel.Items, rssItem{
+			Title:       s.Title,
+			Link:        link,
+			GUID:        rssGUID{IsPermaLink: true, Value: link},
+			PubDate:     s.Created.UTC().Format(time.RFC1123Z),
+			Description: shareableContent(s),
+		})
+	}
+
+	app.serveFeed(w, r, "application/rss+xml; charset=utf-8", doc)
+}
+
+// serveFeed writes an XML document, answering conditional requests from readers polling the feed
+// conditional requests only use the ETag, removing the newest snippet would move Last-Modified backwards
+func (app *application) serveFeed(w http.ResponseWriter, r *http.Request, contentType string, doc interface{}) {
+	buf := bytes.NewBufferString(xml.Header)
+	err := xml.NewEncoder(buf).Encode(doc)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	sum := sha256.Sum256(buf.Bytes())
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("ETag", fmt.Sprintf(`"%s"`, hex.EncodeToString(sum[:16])))
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(buf.Bytes()))
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"robert-tu.net/snippetbox/pkg/forms"
+	"robert-tu.net/snippetbox/pkg/highlight"
+	"robert-tu.net/snippetbox/pkg/markdown"
+	"robert-tu.net/snippetbox/pkg/models"
+)
+
+// most additional files a snippet can hold next to its main content
+const maxFiles = 9
+
+// validFilenameRX matches file names that are safe inside a zip archive
+var validFilenameRX = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// define fileField type for the inputs of one additional file in the create form
+type fileField struct {
+	Index    int
+	Name     string
+	Language string
+	Content  string
+}
+
+// define fileView type for an additional file shown below the main content
+type fileView struct {
+	Name     string
+	Language string
+	Lines    []highlight.Line
+	Rendered template.HTML
+}
+
+// newFileView renders markdown files and highlights the rest
+func newFileView(f *models.File) (fileView, error) {
+	view := fileView{Name: f.Name, Language: f.Language}
+	var err error
+	if f.Language == highlight.Markdown {
+		view.Rendered, err = markdown.Render(f.Content)
+	} else {
+		view.Lines, err = highlight.Lines(f.Content, f.Language)
+	}
+	return view, err
+}
+
+// fileFields reads the repeated file_name, file_language and file_content inputs of a form
+func fileFields(form *forms.Form) []fileField {
+	names := form.Values["file_name"]
+	languages := form.Values["file_language"]
+	contents := form.Values["file_content"]
+
+	n := len(names)
+	if len(contents) > n {
+		n = len(contents)
+	}
+	fields := make([]fileField, n)
+	for i := range fields {
+		fields[i].Index = i
+		if i < len(names) {
+			fields[i].Name = strings.TrimSpace(names[i])
+		}
+		if i < len(languages) {
+			fields[i].Language = languages[i]
+		}
+		if i < len(contents) {
+			fields[i].Content = contents[i]
+		}
+	}
+	return fields
+}
+
+// editFileFields applies the add_file and remove_file buttons of the create form
+// returns false if neither was pressed
+func editFileFields(form *forms.Form, fields []fileField) ([]fileField, bool) {
+	switch {
+	case form.Get("add_file") != "":
+		fields = append(fields, fileField{Index: len(fields)})
+	case form.Get("remove_file") != "":
+		i, err := strconv.Atoi(form.Get("remove_file"))
+		if err == nil && i >= 0 && i < len(fields) {
+			fields = append(fields[:i], fields[i+1:]...)
+		}
+	default:
+		return fields, false
+	}
+	for i := range fields {
+		fields[i].Index = i
+	}
+	return fields, true
+}
+
+// validFiles checks the additional files of a form and returns them
+// errors are reported under the files key, a missing language is guessed from the name
+func validFiles(form *forms.Form, fields []fileField) []*models.File {
+	if len(fields) == 0 {
+		return nil
+	}
+	if len(fields) > maxFiles {
+		form.Errors.Add("files", fmt.Sprintf("A snippet can have at most %d additional files", maxFiles))
+		return nil
+	}
+
+	// the main file needs a name to tell it apart
+	form.Require("filename")
+	seen := map[string]bool{strings.ToLower(form.Get("filename")): true}
+
+	files := make([]*models.File, len(fields))
+	for i, f := range fields {
+		switch {
+		case f.Name == "":
+			form.Errors.Add("files", fmt.Sprintf("File %d needs a name", i+1))
+		case utf8.RuneCountInString(f.Name) > 255 || !validFilenameRX.MatchString(f.Name):
+			form.Errors.Add("files", fmt.Sprintf("The file name %q is invalid (use letters, numbers and ._-)", f.Name))
+		case seen[strings.ToLower(f.Name)]:
+			form.Errors.Add("files", fmt.Sprintf("The file name %q is used more than once", f.Name))
+		case strings.TrimSpace(f.Content) == "":
+			form.Errors.Add("files", fmt.Sprintf("The file %q is empty", f.Name))
+		}
+		seen[strings.ToLower(f.Name)] = true
+
+		language := f.Language
+		if _, ok := highlight.Lookup(language); language != "" && !ok {
+			form.Errors.Add("files", fmt.Sprintf("The language of %q is invalid", f.Name))
+		}
+		if language == "" {
+			language = guessLanguage(f.Name, f.Content)
+		}
+		files[i] = &models.File{Name: f.Name, Language: language, Content: f.Content}
+	}
+	return files
+}
+
+// guessLanguage picks a language from a file name, falling back to its content
+func guessLanguage(name, content string) string {
+	if language, ok := highlight.ForFilename(name); ok {
+		return language
+	}
+	return highlight.Detect(content)
+}
+
+// mainFilename returns the name of the main file of a snippet
+func mainFilename(s *models.Snippet) string {
+	if s.Filename != "" {
+		return s.Filename
+	}
+	return snippetFilename(s)
+}
+
+// snippetFiles returns every file of a snippet, the main content first
+func snippetFiles(s *models.Snippet) []*models.File {
+	main := &models.File{Name: mainFilename(s), Language: s.Language, Content: s.Content}
+	return append([]*models.File{main}, s.Files...)
+}
+
+// zipFiles packs files into a zip archive
+func zipFiles(files []*models.File) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	zw := zip.NewWriter(buf)
+	for _, f := range files {
+		w, err := zw.Create(f.Name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = w.Write([]byte(f.Content)); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// fileFromRequest loads a readable snippet and the file named by the file query parameter
+// the main content is returned when the parameter is blank
+func (app *application) fileFromRequest(w http.ResponseWriter, r *http.Request) (*models.Snippet, *models.File, bool) {
+	s, ok := app.snippetFromRequest(w, r)
+	if !ok || !app.requireReadable(w, r, s) {
+		return nil, nil, false
+	}
+
+	name := r.URL.Query().Get("file")
+	if name == "" {
+		return s, snippetFiles(s)[0], true
+	}
+
+	files, err := app.files.ForSnippet(s.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return nil, nil, false
+	}
+	s.Files = files
+	for _, f := range snippetFiles(s) {
+		if f.Name == name {
+			return s, f, true
+		}
+	}
+	app.notFound(w)
+	return nil, nil, false
+}
@@ -1,12 +1,27 @@
 package main
 
 import (
+	"archive/zip"
 	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"html"
+	"image/png"
 	"io"
 	"log"
 	"net/http"
 	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
 	"testing"
+	"time"
+
+	"robert-tu.net/snippetbox/pkg/blob"
+	"robert-tu.net/snippetbox/pkg/models/mock"
 )
 
 func TestPing(t *testing.T) {
@@ -48,12 +63,28 @@ func TestShowSnippet(t *testing.T) {
 		wantBody []byte
 	}{
 		{"Valid ID", "/snippet/1", http.StatusOK, []byte("...")},
+		{"Owner", "/snippet/1", http.StatusOK, []byte("By: Alice")},
+		{"Tags", "/snippet/1", http.StatusOK, []byte("<a href='/tag/haiku' class='tag'>haiku</a>")},
+		{"Selected line", "/snippet/1?lines=1", http.StatusOK, []byte("<tr id='L1' class='selected'>")},
+		{"Markdown", "/snippet/4", http.StatusOK, []byte("<h1>Notes</h1>")},
+		{"Markdown source", "/snippet/4?view=source", http.StatusOK, []byte("View rendered")},
 		{"Non-existent ID", "/snippet/2", http.StatusNotFound, nil},
 		{"Negative ID", "/snippet/-1", http.StatusNotFound, nil},
 		{"Decimal ID", "/snippet/0.1", http.StatusNotFound, nil},
 		{"String ID", "/snippet/blah", http.StatusNotFound, nil},
 		{"Empty ID", "/snippet/", http.StatusNotFound, nil},
 		{"Trailing slash", "/snippet/1/", http.StatusNotFound, nil},
+		{"History", "/snippet/1/history", http.StatusOK, []byte("<td>v1</td>")},
+		{"History non-existent ID", "/snippet/2/history", http.StatusNotFound, nil},
+		{"Slug", "/s/c2lsZW50LXBvbmQtc2x1Zw", http.StatusOK, []byte("An old silent pond")},
+		{"Non-existent slug", "/s/blah", http.StatusNotFound, nil},
+		{"Unlisted by ID", "/snippet/5", http.StatusNotFound, nil},
+		{"Unlisted history", "/snippet/5/history", http.StatusNotFound, nil},
+		{"Unlisted by slug", "/s/dW5saXN0ZWQtc25pcHBldA", http.StatusOK, []byte("First autumn morning")},
+		{"Unlisted history link", "/s/dW5saXN0ZWQtc25pcHBldA", http.StatusOK, []byte("<a href='/s/dW5saXN0ZWQtc25pcHBldA/history'>")},
+		{"Unlisted history by slug", "/s/dW5saXN0ZWQtc25pcHBldA/history", http.StatusOK, []byte("History of <a href='/s/dW5saXN0ZWQtc25pcHBldA'>")},
+		{"Private by ID", "/snippet/6", http.StatusNotFound, nil},
+		{"Private by slug", "/s/cHJpdmF0ZS1zbmlwcGV0LQ", http.StatusNotFound, nil},
 	}
 
 	for _, tt := range tests {
@@ -123,3 +154,1516 @@ func TestSignupUser(t *testing.T) {
 		})
 	}
 }
+
+func TestUserSnippets(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// unauthenticated users are redirected to login
+	code, header, _ := ts.get(t, "/user/snippets")
+	if code != http.StatusSeeOther {
+		t.Errorf("want %d; got %d", http.StatusSeeOther, code)
+	}
+	if loc := header.Get("Location"); loc != "/user/login" {
+		t.Errorf("want redirect to %q; got %q", "/user/login", loc)
+	}
+
+	ts.login(t)
+
+	code, _, body := ts.get(t, "/user/snippets")
+	if code != http.StatusOK {
+		t.Errorf("want %d; got %d", http.StatusOK, code)
+	}
+	if !bytes.Contains(body, []byte("An old silent pond")) {
+		t.Errorf("want body to contain %q", "An old silent pond")
+	}
+}
+
+func TestEditSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	csrfToken := ts.login(t)
+
+	code, _, body := ts.get(t, "/snippet/1/edit")
+	if code != http.StatusOK {
+		t.Errorf("want %d; got %d", http.StatusOK, code)
+	}
+	if !bytes.Contains(body, []byte("name='version' value='1'")) {
+		t.Errorf("want body to contain the current version")
+	}
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		title    string
+		version  string
+		wantCode int
+		wantBody []byte
+	}{
+		{"Valid", "/snippet/1/edit", "New title", "1", http.StatusSeeOther, nil},
+		{"Empty title", "/snippet/1/edit", "", "1", http.StatusOK, []byte("This field cannot be blank")},
+		{"Stale version", "/snippet/1/edit", "New title", "0", http.StatusOK, []byte("changed while you were editing")},
+		{"Invalid version", "/snippet/1/edit", "New title", "blah", http.StatusBadRequest, nil},
+		{"Non-existent ID", "/snippet/2/edit", "New title", "1", http.StatusNotFound, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", tt.title)
+			form.Add("content", "...")
+			form.Add("version", tt.version)
+			form.Add("csrf_token", csrfToken)
+
+			code, _, body := ts.postForm(t, tt.urlPath, form)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body %s to contain %q", body, tt.wantBody)
+			}
+		})
+	}
+}
+
+// updateModel records the language of the last snippet update
+type updateModel struct {
+	mock.SnippetModel
+	language string
+}
+
+func (m *updateModel) Update(id, userID, version int, title, content, language, visibility string, tags []string) error {
+	m.language = language
+	return m.SnippetModel.Update(id, userID, version, title, content, language, visibility, tags)
+}
+
+func TestEditSnippetLanguage(t *testing.T) {
+	app := newTestApplication(t)
+	snippets := &updateModel{}
+	app.snippets = snippets
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	csrfToken := ts.login(t)
+
+	// the filename of snippet 1 wins over content that looks like Go
+	form := url.Values{}
+	form.Add("title", "An old silent pond")
+	form.Add("content", "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(\"pond\")\n}\n")
+	form.Add("version", "1")
+	form.Add("csrf_token", csrfToken)
+
+	code, _, _ := ts.postForm(t, "/snippet/1/edit", form)
+	if code != http.StatusSeeOther {
+		t.Fatalf("want %d; got %d", http.StatusSeeOther, code)
+	}
+	if snippets.language != "plaintext" {
+		t.Errorf("want language %q; got %q", "plaintext", snippets.language)
+	}
+}
+
+func TestSnippetDiff(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name            string
+		urlPath         string
+		wantCode        int
+		wantContentType string
+		wantBody        []byte
+	}{
+		{"Latest revision", "/snippet/1/diff", http.StatusOK, "text/html; charset=utf-8", []byte("No differences.")},
+		{"Unified", "/snippet/1/diff?from=1&to=2", http.StatusOK, "text/html; charset=utf-8", []byte("<pre>A frog jumps into the pond</pre>")},
+		{"Side by side", "/snippet/1/diff?from=1&to=2&view=split", http.StatusOK, "text/html; charset=utf-8", []byte("view=unified")},
+		{"Patch", "/snippet/1/diff?from=1&to=2&format=patch", http.StatusOK, "text/x-diff; charset=utf-8", []byte("--- snippet-1-v1\n+++ snippet-1-v2\n@@ -1 +1,2 @@\n ...\n+A frog jumps into the pond\n")},
+		{"Non-existent revision", "/snippet/1/diff?from=1&to=3", http.StatusNotFound, "", nil},
+		{"Invalid revision", "/snippet/1/diff?to=blah", http.StatusBadRequest, "", nil},
+		{"Non-existent ID", "/snippet/2/diff", http.StatusNotFound, "", nil},
+		{"Compare", "/compare/1/1", http.StatusOK, "text/html; charset=utf-8", []byte("No differences.")},
+		{"Compare non-existent ID", "/compare/1/2", http.StatusNotFound, "", nil},
+		{"Compare too large", "/compare/1/10", http.StatusOK, "text/html; charset=utf-8", []byte("Too large to diff.")},
+		{"Compare too large patch", "/compare/1/10?format=patch", http.StatusUnprocessableEntity, "", nil},
+		{"By slug", "/s/c2lsZW50LXBvbmQtc2x1Zw/diff?from=1&to=2", http.StatusOK, "text/html; charset=utf-8", []byte("<pre>A frog jumps into the pond</pre>")},
+		{"Compare unlisted by ID", "/compare/5/1", http.StatusNotFound, "", nil},
+		{"Compare unlisted by slug", "/compare/s/dW5saXN0ZWQtc25pcHBldA/c2lsZW50LXBvbmQtc2x1Zw", http.StatusOK, "text/html; charset=utf-8", []byte("No differences.")},
+		{"Compare by slug links", "/compare/s/dW5saXN0ZWQtc25pcHBldA/c2lsZW50LXBvbmQtc2x1Zw", http.StatusOK, "text/html; charset=utf-8", []byte("/compare/s/dW5saXN0ZWQtc25pcHBldA/c2lsZW50LXBvbmQtc2x1Zw?format=patch")},
+		{"Compare non-existent slug", "/compare/s/blah/c2lsZW50LXBvbmQtc2x1Zw", http.StatusNotFound, "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, header, body := ts.get(t, tt.urlPath)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if tt.wantContentType != "" && header.Get("Content-Type") != tt.wantContentType {
+				t.Errorf("want Content-Type %q; got %q", tt.wantContentType, header.Get("Content-Type"))
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body %s to contain %q", body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestDeleteAndRestoreSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	csrfToken := ts.login(t)
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		csrfToken    string
+		wantCode     int
+		wantLocation string
+	}{
+		{"Delete", "/snippet/1/delete", csrfToken, http.StatusSeeOther, "/user/trash"},
+		{"Delete non-existent ID", "/snippet/2/delete", csrfToken, http.StatusNotFound, ""},
+		{"Delete invalid CSRF token", "/snippet/1/delete", "notToken", http.StatusBadRequest, ""},
+		{"Restore", "/snippet/3/restore", csrfToken, http.StatusSeeOther, "/snippet/3"},
+		{"Restore snippet not in trash", "/snippet/1/restore", csrfToken, http.StatusNotFound, ""},
+		{"Restore invalid ID", "/snippet/blah/restore", csrfToken, http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("csrf_token", tt.csrfToken)
+
+			code, header, _ := ts.postForm(t, tt.urlPath, form)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if loc := header.Get("Location"); loc != tt.wantLocation {
+				t.Errorf("want Location %q; got %q", tt.wantLocation, loc)
+			}
+		})
+	}
+
+	code, _, body := ts.get(t, "/user/trash")
+	if code != http.StatusOK {
+		t.Errorf("want %d; got %d", http.StatusOK, code)
+	}
+	if !bytes.Contains(body, []byte("Over the wintry forest")) {
+		t.Errorf("want body to contain %q", "Over the wintry forest")
+	}
+}
+
+func TestListSnippets(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// home links to the next page
+	code, _, body := ts.get(t, "/")
+	if code != http.StatusOK {
+		t.Errorf("want %d; got %d", http.StatusOK, code)
+	}
+	next := regexp.MustCompile(`href='(/snippets\?before=[^']+)'`).FindSubmatch(body)
+	if next == nil {
+		t.Fatalf("want body %s to link to the next page", body)
+	}
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+	}{
+		{"Next page", html.UnescapeString(string(next[1])), http.StatusOK},
+		{"Custom limit", "/snippets?limit=50", http.StatusOK},
+		{"Invalid cursor", "/snippets?before=blah", http.StatusBadRequest},
+		{"Zero limit", "/snippets?limit=0", http.StatusBadRequest},
+		{"Limit too large", "/snippets?limit=101", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, _ := ts.get(t, tt.urlPath)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+		})
+	}
+}
+
+func TestSearch(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody []byte
+	}{
+		{"Form only", "/search", http.StatusOK, []byte("name='q'")},
+		{"Match", "/search?q=pond", http.StatusOK, []byte("An old silent <mark>pond</mark>")},
+		{"No match", "/search?q=frog", http.StatusOK, []byte("No snippets match your search.")},
+		{"Invalid page", "/search?q=pond&page=0", http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body %s to contain %q", body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestSearchViewLimited(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// searching must not use up a view of a burn after reading snippet
+	code, _, body := ts.get(t, "/search?q=lightning")
+
+	if code != http.StatusOK {
+		t.Errorf("want %d; got %d", http.StatusOK, code)
+	}
+	if !bytes.Contains(body, []byte("<mark>Lightning</mark> flash")) {
+		t.Errorf("want body %s to contain the matching title", body)
+	}
+	if bytes.Contains(body, []byte("What I thought were faces")) {
+		t.Errorf("want body %s to leave out view-limited content", body)
+	}
+}
+
+func TestCreateSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	csrfToken := ts.login(t)
+
+	tests := []struct {
+		name         string
+		title        string
+		expires      string
+		tags         string
+		maxViews     string
+		wantCode     int
+		wantLocation string
+		wantBody     []byte
+	}{
+		{"Valid", "Title", "1w", "", "", http.StatusSeeOther, "/snippet/2", nil},
+		{"Valid tags", "Title", "1w", "Go, http,go project-x", "", http.StatusSeeOther, "/snippet/2", nil},
+		{"Never expires", "Title", "never", "", "", http.StatusSeeOther, "/snippet/2", nil},
+		{"Empty title", "", "1w", "", "", http.StatusOK, "", []byte("This field cannot be blank")},
+		{"Invalid expires", "Title", "2", "", "", http.StatusOK, "", []byte("This field is invalid")},
+		{"Invalid tag", "Title", "1w", "go, <b>", "", http.StatusOK, "", []byte("is invalid")},
+		{"Burn after reading", "Title", "1w", "", "1", http.StatusSeeOther, "/snippet/2", nil},
+		{"Invalid view limit", "Title", "1w", "", "0", http.StatusOK, "", []byte("must be a number from 1 to 1000")},
+		{"Too many tags", "Title", "1w", "a b c d e f g h i j k", "", http.StatusOK, "", []byte("too many tags (max 10)")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", tt.title)
+			form.Add("content", "...")
+			form.Add("expires", tt.expires)
+			form.Add("tags", tt.tags)
+			form.Add("max_views", tt.maxViews)
+			form.Add("csrf_token", csrfToken)
+
+			code, header, body := ts.postForm(t, "/snippet/create", form)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if loc := header.Get("Location"); loc != tt.wantLocation {
+				t.Errorf("want Location %q; got %q", tt.wantLocation, loc)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body %s to contain %q", body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestTagSnippets(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody []byte
+	}{
+		{"Tag cloud", "/", http.StatusOK, []byte("<a href='/tag/poetry' class='tag weight-5'")},
+		{"Tagged", "/tag/haiku", http.StatusOK, []byte("An old silent pond")},
+		{"Unused tag", "/tag/c%2B%2B", http.StatusOK, []byte("No snippets have this tag.")},
+		{"Invalid page", "/tag/haiku?page=blah", http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body %s to contain %q", body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestShowMarkdownSnippetSanitized(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, body := ts.get(t, "/snippet/4")
+	if code != http.StatusOK {
+		t.Fatalf("want %d; got %d", http.StatusOK, code)
+	}
+
+	if bytes.Contains(body, []byte("alert(1)")) {
+		t.Errorf("want body %s not to contain the injected script", body)
+	}
+}
+
+func TestOwnerSeesHiddenSnippets(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantBody []byte
+	}{
+		{"Unlisted by ID", "/snippet/5", []byte("<a href='/s/dW5saXN0ZWQtc25pcHBldA'>Share link</a>")},
+		{"Private by ID", "/snippet/6", []byte("A world of dew")},
+		{"Private by slug", "/s/cHJpdmF0ZS1zbmlwcGV0LQ", []byte("A world of dew")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			if code != http.StatusOK {
+				t.Errorf("want %d; got %d", http.StatusOK, code)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestUnlockSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// locked snippets show the unlock form instead of their content
+	code, _, body := ts.get(t, "/snippet/7")
+	if code != http.StatusOK {
+		t.Fatalf("want %d; got %d", http.StatusOK, code)
+	}
+	if bytes.Contains(body, []byte("The temple bell stops")) {
+		t.Fatal("want content to be hidden before unlocking")
+	}
+	csrfToken := extractCSRFToken(t, body)
+
+	code, _, _ = ts.get(t, "/snippet/7/diff")
+	if code != http.StatusSeeOther {
+		t.Errorf("want %d for diff of locked snippet; got %d", http.StatusSeeOther, code)
+	}
+
+	tests := []struct {
+		name       string
+		passphrase string
+		wantCode   int
+		wantBody   []byte
+	}{
+		{"Wrong passphrase", "open barley", http.StatusOK, []byte("Passphrase is incorrect")},
+		{"Right passphrase", "open sesame", http.StatusSeeOther, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("passphrase", tt.passphrase)
+			form.Add("csrf_token", csrfToken)
+
+			code, _, body := ts.postForm(t, "/snippet/7/unlock", form)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+
+	// the unlock is remembered for the rest of the session
+	_, _, body = ts.get(t, "/snippet/7")
+	if !bytes.Contains(body, []byte("The temple bell stops")) {
+		t.Error("want content to be shown after unlocking")
+	}
+}
+
+func TestRevealSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// opening the link only shows a warning
+	code, _, body := ts.get(t, "/snippet/8")
+	if code != http.StatusOK {
+		t.Fatalf("want %d; got %d", http.StatusOK, code)
+	}
+	if !bytes.Contains(body, []byte("destroyed as soon as you view it")) {
+		t.Errorf("want body to contain %q", "destroyed as soon as you view it")
+	}
+	if bytes.Contains(body, []byte("What I thought were faces")) {
+		t.Error("want content to be hidden before confirming")
+	}
+	csrfToken := extractCSRFToken(t, body)
+
+	code, _, _ = ts.get(t, "/snippet/8/history")
+	if code != http.StatusSeeOther {
+		t.Errorf("want %d for history of view limited snippet; got %d", http.StatusSeeOther, code)
+	}
+
+	form := url.Values{}
+	form.Add("csrf_token", csrfToken)
+	code, header, body := ts.postForm(t, "/snippet/8/view", form)
+	if code != http.StatusOK {
+		t.Fatalf("want %d; got %d", http.StatusOK, code)
+	}
+	if cc := header.Get("Cache-Control"); cc != "no-store" {
+		t.Errorf("want Cache-Control %q; got %q", "no-store", cc)
+	}
+	for _, want := range [][]byte{[]byte("What I thought were faces"), []byte("This was the last view")} {
+		if !bytes.Contains(body, want) {
+			t.Errorf("want body to contain %q", want)
+		}
+	}
+}
+
+func TestUpdateExpiry(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	csrfToken := ts.login(t)
+
+	code, _, body := ts.get(t, "/snippet/1/expiry")
+	if code != http.StatusOK {
+		t.Errorf("want %d; got %d", http.StatusOK, code)
+	}
+	if !bytes.Contains(body, []byte("value='1w'")) {
+		t.Errorf("want body to contain the expiry presets")
+	}
+
+	tomorrow := time.Now().UTC().AddDate(0, 0, 1).Format("2006-01-02")
+
+	tests := []struct {
+		name      string
+		expires   string
+		expiresOn string
+		wantCode  int
+		wantBody  []byte
+	}{
+		{"Preset", "1d", "", http.StatusSeeOther, nil},
+		{"Never", "never", "", http.StatusSeeOther, nil},
+		{"Custom date", "custom", tomorrow, http.StatusSeeOther, nil},
+		{"Past date", "custom", "2000-01-01", http.StatusOK, []byte("This date must be in the future")},
+		{"Far date", "custom", "2999-01-01", http.StatusOK, []byte("This date is too far in the future")},
+		{"Invalid date", "custom", "tomorrow", http.StatusOK, []byte("This field must be a date")},
+		{"Unknown preset", "365", "", http.StatusOK, []byte("This field is invalid")},
+		{"Empty", "", "", http.StatusOK, []byte("This field cannot be blank")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("expires", tt.expires)
+			form.Add("expires_on", tt.expiresOn)
+			form.Add("csrf_token", csrfToken)
+
+			code, _, body := ts.postForm(t, "/snippet/1/expiry", form)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body %s to contain %q", body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestRawSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name            string
+		urlPath         string
+		wantCode        int
+		wantContentType string
+		wantDisposition string
+		wantBody        []byte
+	}{
+		{"Raw", "/snippet/1/raw", http.StatusOK, "text/plain; charset=utf-8", "", []byte("...")},
+		{"Raw markdown", "/snippet/4/raw", http.StatusOK, "text/plain; charset=utf-8", "", []byte("<script>alert(1)</script>")},
+		{"Raw by slug", "/s/dW5saXN0ZWQtc25pcHBldA/raw", http.StatusOK, "text/plain; charset=utf-8", "", []byte("...")},
+		{"Download", "/snippet/1/download", http.StatusOK, "text/plain; charset=utf-8", "attachment; filename=pond.txt", []byte("...")},
+		{"Raw file", "/snippet/1/raw?file=frog.go", http.StatusOK, "text/plain; charset=utf-8", "", []byte("package frog")},
+		{"Download file", "/snippet/1/download?file=frog.go", http.StatusOK, "text/plain; charset=utf-8", "attachment; filename=frog.go", []byte("package frog")},
+		{"Missing file", "/snippet/1/raw?file=toad.go", http.StatusNotFound, "", "", nil},
+		{"Download markdown", "/snippet/4/download", http.StatusOK, "text/plain; charset=utf-8", "attachment; filename=notes.md", nil},
+		{"Unlisted by ID", "/snippet/5/raw", http.StatusNotFound, "", "", nil},
+		{"Private", "/snippet/6/download", http.StatusNotFound, "", "", nil},
+		{"Locked", "/snippet/7/raw", http.StatusSeeOther, "", "", nil},
+		{"View limited", "/snippet/8/raw", http.StatusSeeOther, "", "", nil},
+		{"Non-existent ID", "/snippet/2/raw", http.StatusNotFound, "", "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, header, body := ts.get(t, tt.urlPath)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if tt.wantContentType != "" && header.Get("Content-Type") != tt.wantContentType {
+				t.Errorf("want Content-Type %q; got %q", tt.wantContentType, header.Get("Content-Type"))
+			}
+
+			if header.Get("Content-Disposition") != tt.wantDisposition {
+				t.Errorf("want Content-Disposition %q; got %q", tt.wantDisposition, header.Get("Content-Disposition"))
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body %s to contain %q", body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestEmbedSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name            string
+		urlPath         string
+		wantCode        int
+		wantContentType string
+		wantBody        []byte
+	}{
+		{"Embed", "/snippet/1/embed", http.StatusOK, "text/html; charset=utf-8", []byte("An old silent pond")},
+		{"Embed markdown", "/snippet/4/embed", http.StatusOK, "text/html; charset=utf-8", []byte("<div class='markdown'>")},
+		{"Embed by slug", "/s/dW5saXN0ZWQtc25pcHBldA/embed", http.StatusOK, "text/html; charset=utf-8", []byte(ts.URL + "/s/dW5saXN0ZWQtc25pcHBldA")},
+		{"Script", "/snippet/1/embed.js?lines=2", http.StatusOK, "text/javascript; charset=utf-8", []byte(`"` + ts.URL + `/snippet/1/embed?lines=2"`)},
+		{"Script by slug", "/s/dW5saXN0ZWQtc25pcHBldA/embed.js", http.StatusOK, "text/javascript; charset=utf-8", []byte(ts.URL + "/s/dW5saXN0ZWQtc25pcHBldA/embed")},
+		{"Unlisted by ID", "/snippet/5/embed", http.StatusNotFound, "", nil},
+		{"Private", "/snippet/6/embed", http.StatusNotFound, "", nil},
+		{"Protected", "/snippet/7/embed", http.StatusForbidden, "", nil},
+		{"View limited", "/snippet/8/embed.js", http.StatusForbidden, "", nil},
+		{"Non-existent ID", "/snippet/2/embed", http.StatusNotFound, "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, header, body := ts.get(t, tt.urlPath)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if tt.wantContentType != "" && header.Get("Content-Type") != tt.wantContentType {
+				t.Errorf("want Content-Type %q; got %q", tt.wantContentType, header.Get("Content-Type"))
+			}
+
+			// only the framed page needs the exception
+			if code == http.StatusOK && strings.HasSuffix(tt.urlPath, "/embed") && header.Get("X-Frame-Options") != "" {
+				t.Errorf("want no X-Frame-Options; got %q", header.Get("X-Frame-Options"))
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body %s to contain %q", body, tt.wantBody)
+			}
+		})
+	}
+
+	// framing stays denied everywhere else
+	_, header, body := ts.get(t, "/snippet/1")
+	if header.Get("X-Frame-Options") != "deny" {
+		t.Errorf("want X-Frame-Options deny; got %q", header.Get("X-Frame-Options"))
+	}
+	if !bytes.Contains(body, []byte("application/json+oembed")) {
+		t.Errorf("want snippet page to link its oEmbed description")
+	}
+}
+
+func TestOEmbed(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	oembedPath := func(link string, extra ...string) string {
+		return "/oembed?" + url.Values{"url": {link}}.Encode() + strings.Join(extra, "")
+	}
+
+	tests := []struct {
+		name       string
+		urlPath    string
+		wantCode   int
+		wantWidth  int
+		wantHeight int
+		wantSrc    string
+	}{
+		{"Snippet", oembedPath(ts.URL + "/snippet/1"), http.StatusOK, embedWidth, embedHeaderHeight + embedLineHeight, ts.URL + "/snippet/1/embed"},
+		{"Slug", oembedPath(ts.URL + "/s/dW5saXN0ZWQtc25pcHBldA"), http.StatusOK, embedWidth, embedHeaderHeight + embedLineHeight, ts.URL + "/s/dW5saXN0ZWQtc25pcHBldA/embed"},
+		{"Max size", oembedPath(ts.URL+"/snippet/1", "&maxwidth=300&maxheight=50"), http.StatusOK, 300, 50, ts.URL + "/snippet/1/embed"},
+		{"XML", oembedPath(ts.URL+"/snippet/1", "&format=xml"), http.StatusNotImplemented, 0, 0, ""},
+		{"Other site", oembedPath("https://example.com/snippet/1"), http.StatusNotFound, 0, 0, ""},
+		{"Not a snippet", oembedPath(ts.URL + "/user/login"), http.StatusNotFound, 0, 0, ""},
+		{"Unlisted by ID", oembedPath(ts.URL + "/snippet/5"), http.StatusNotFound, 0, 0, ""},
+		{"Private", oembedPath(ts.URL + "/snippet/6"), http.StatusNotFound, 0, 0, ""},
+		{"Protected", oembedPath(ts.URL + "/snippet/7"), http.StatusUnauthorized, 0, 0, ""},
+		{"Bad size", oembedPath(ts.URL+"/snippet/1", "&maxwidth=wide"), http.StatusBadRequest, 0, 0, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, header, body := ts.get(t, tt.urlPath)
+
+			if code != tt.wantCode {
+				t.Fatalf("want %d; got %d", tt.wantCode, code)
+			}
+			if code != http.StatusOK {
+				return
+			}
+
+			if header.Get("Content-Type") != "application/json" {
+				t.Errorf("want Content-Type application/json; got %q", header.Get("Content-Type"))
+			}
+
+			var rs oembedResponse
+			if err := json.Unmarshal(body, &rs); err != nil {
+				t.Fatal(err)
+			}
+			if rs.Type != "rich" || rs.Version != "1.0" {
+				t.Errorf("want rich oEmbed 1.0; got %s %s", rs.Type, rs.Version)
+			}
+			if rs.Width != tt.wantWidth || rs.Height != tt.wantHeight {
+				t.Errorf("want %dx%d; got %dx%d", tt.wantWidth, tt.wantHeight, rs.Width, rs.Height)
+			}
+			if !strings.Contains(rs.HTML, `src="`+tt.wantSrc+`"`) {
+				t.Errorf("want html %q to frame %q", rs.HTML, tt.wantSrc)
+			}
+		})
+	}
+}
+
+func TestFeeds(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name            string
+		urlPath         string
+		wantCode        int
+		wantContentType string
+		wantBody        []byte
+	}{
+		{"Atom", "/feed.atom", http.StatusOK, "application/atom+xml; charset=utf-8", []byte("<link href=\"" + ts.URL + "/snippet/1\" rel=\"alternate\" type=\"text/html\"></link>")},
+		{"RSS", "/feed.rss", http.StatusOK, "application/rss+xml; charset=utf-8", []byte("<guid isPermaLink=\"true\">" + ts.URL + "/snippet/1</guid>")},
+		{"Tag", "/tag/haiku/feed.atom", http.StatusOK, "application/atom+xml; charset=utf-8", []byte("<title>Snippets tagged haiku - Snippetbox</title>")},
+		{"Empty tag", "/tag/prose/feed.rss", http.StatusOK, "application/rss+xml; charset=utf-8", []byte("<title>Snippets tagged prose - Snippetbox</title>")},
+		{"Author", "/user/1/feed.atom", http.StatusOK, "application/atom+xml; charset=utf-8", []byte("<title>Snippets by Alice - Snippetbox</title>")},
+		{"Missing author", "/user/2/feed.rss", http.StatusNotFound, "", nil},
+		{"Bad author", "/user/alice/feed.atom", http.StatusNotFound, "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, header, body := ts.get(t, tt.urlPath)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if tt.wantContentType != "" && header.Get("Content-Type") != tt.wantContentType {
+				t.Errorf("want Content-Type %q; got %q", tt.wantContentType, header.Get("Content-Type"))
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body %s to contain %q", body, tt.wantBody)
+			}
+		})
+	}
+
+	// the empty feed has no entries
+	_, _, body := ts.get(t, "/tag/prose/feed.rss")
+	if bytes.Contains(body, []byte("<item>")) {
+		t.Errorf("want no items in %s", body)
+	}
+
+	// readers polling an unchanged feed get 304
+	_, header, _ := ts.get(t, "/feed.atom")
+	if header.Get("ETag") == "" {
+		t.Fatal("want ETag header")
+	}
+	// removing the newest snippet would move Last-Modified backwards
+	if header.Get("Last-Modified") != "" {
+		t.Errorf("want no Last-Modified header; got %q", header.Get("Last-Modified"))
+	}
+	conditional := []struct {
+		name     string
+		value    string
+		wantCode int
+	}{
+		{"If-None-Match", header.Get("ETag"), http.StatusNotModified},
+		{"If-Modified-Since", time.Now().UTC().Format(http.TimeFormat), http.StatusOK},
+	}
+	for _, tt := range conditional {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", ts.URL+"/feed.atom", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set(tt.name, tt.value)
+			rs, err := ts.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rs.Body.Close()
+
+			if rs.StatusCode != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, rs.StatusCode)
+			}
+		})
+	}
+
+	// a changed feed is sent again
+	req, err := http.NewRequest("GET", ts.URL+"/feed.atom", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("If-None-Match", `"stale"`)
+	rs, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs.Body.Close()
+	if rs.StatusCode != http.StatusOK {
+		t.Errorf("want %d; got %d", http.StatusOK, rs.StatusCode)
+	}
+}
+
+func TestAtomFeedEntries(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/feed.atom")
+
+	var doc atomFeed
+	if err := xml.Unmarshal(body, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Entries) != 1 {
+		t.Fatalf("want 1 entry; got %d", len(doc.Entries))
+	}
+	entry := doc.Entries[0]
+	if entry.Updated == "" || entry.Updated != doc.Updated {
+		t.Errorf("want entry and feed updated when the snippet was created; got %q and %q", entry.Updated, doc.Updated)
+	}
+	if entry.Author.Name != "Alice" || entry.Content == nil || entry.Content.Body != "..." {
+		t.Errorf("want entry by Alice with the snippet content; got %+v", entry)
+	}
+}
+
+func TestCardImage(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+	}{
+		{"Public", "/snippet/1/card.png", http.StatusOK},
+		{"By slug", "/s/dW5saXN0ZWQtc25pcHBldA/card.png", http.StatusOK},
+		{"Protected", "/snippet/7/card.png", http.StatusOK},
+		{"Private", "/snippet/6/card.png", http.StatusNotFound},
+		{"Non-existent ID", "/snippet/2/card.png", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, header, body := ts.get(t, tt.urlPath)
+
+			if code != tt.wantCode {
+				t.Fatalf("want %d; got %d", tt.wantCode, code)
+			}
+			if code != http.StatusOK {
+				return
+			}
+
+			if header.Get("Content-Type") != "image/png" {
+				t.Errorf("want Content-Type image/png; got %q", header.Get("Content-Type"))
+			}
+			if _, err := png.Decode(bytes.NewReader(body)); err != nil {
+				t.Errorf("want a PNG image: %v", err)
+			}
+		})
+	}
+
+	// drawn cards are kept in the cache
+	s, _ := app.snippets.Get(1)
+	key := snippetCard(s).Key()
+	rc, err := app.cache.Get("cards/" + key + ".png")
+	if err != nil {
+		t.Fatalf("want card to be cached: %v", err)
+	}
+	rc.Close()
+
+	req, err := http.NewRequest("GET", ts.URL+"/snippet/1/card.png", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("If-None-Match", `"`+key[:32]+`"`)
+	rs, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs.Body.Close()
+	if rs.StatusCode != http.StatusNotModified {
+		t.Errorf("want %d; got %d", http.StatusNotModified, rs.StatusCode)
+	}
+
+	// cards that aren't cached yet are refused while every render slot is busy
+	for i := 0; i < cap(app.renders); i++ {
+		app.renders <- struct{}{}
+	}
+	code, header, _ := ts.get(t, "/snippet/4/card.png")
+	if code != http.StatusServiceUnavailable || header.Get("Retry-After") == "" {
+		t.Errorf("want %d with Retry-After; got %d", http.StatusServiceUnavailable, code)
+	}
+	if code, _, _ = ts.get(t, "/snippet/1/card.png"); code != http.StatusOK {
+		t.Errorf("want cached card served with busy slots; got %d", code)
+	}
+	for i := 0; i < cap(app.renders); i++ {
+		<-app.renders
+	}
+}
+
+func TestCodeImage(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name            string
+		urlPath         string
+		wantCode        int
+		wantContentType string
+	}{
+		{"PNG", "/snippet/1/image.png", http.StatusOK, "image/png"},
+		{"SVG", "/snippet/1/image.svg", http.StatusOK, "image/svg+xml"},
+		{"Styled", "/snippet/1/image.png?theme=dracula&padding=0&width=320", http.StatusOK, "image/png"},
+		{"Lines", "/snippet/4/image.svg?lines=1-2", http.StatusOK, "image/svg+xml"},
+		{"By slug", "/s/dW5saXN0ZWQtc25pcHBldA/image.png", http.StatusOK, "image/png"},
+		{"Unknown theme", "/snippet/1/image.png?theme=neon", http.StatusBadRequest, ""},
+		{"Bad padding", "/snippet/1/image.png?padding=-4", http.StatusBadRequest, ""},
+		{"Bad width", "/snippet/1/image.svg?width=10", http.StatusBadRequest, ""},
+		{"Lines past the end", "/snippet/1/image.png?lines=9", http.StatusBadRequest, ""},
+		{"Unlisted by ID", "/snippet/5/image.png", http.StatusNotFound, ""},
+		{"Private", "/snippet/6/image.svg", http.StatusNotFound, ""},
+		{"Locked", "/snippet/7/image.png", http.StatusSeeOther, ""},
+		{"View limited", "/snippet/8/image.svg", http.StatusSeeOther, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, header, body := ts.get(t, tt.urlPath)
+
+			if code != tt.wantCode {
+				t.Fatalf("want %d; got %d", tt.wantCode, code)
+			}
+			if code != http.StatusOK {
+				return
+			}
+
+			if header.Get("Content-Type") != tt.wantContentType {
+				t.Errorf("want Content-Type %q; got %q", tt.wantContentType, header.Get("Content-Type"))
+			}
+			if tt.wantContentType == "image/png" {
+				if _, err := png.Decode(bytes.NewReader(body)); err != nil {
+					t.Errorf("want a PNG image: %v", err)
+				}
+			} else if !bytes.HasPrefix(body, []byte("<svg ")) || bytes.Contains(body, []byte("<script")) {
+				t.Errorf("want an SVG image without scripts; got %s", body)
+			}
+		})
+	}
+
+	// only the picked lines are drawn
+	_, _, body := ts.get(t, "/snippet/4/image.svg?lines=1-2")
+	if bytes.Contains(body, []byte("haiku")) {
+		t.Errorf("want lines after the range left out; got %s", body)
+	}
+
+	// only images in the default style are cached, two of snippet 1 and one by slug
+	entries, err := os.ReadDir(filepath.Join(app.cache.(*blob.FileStore).Dir, "code"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Errorf("want %d cached code images; got %d", 3, len(entries))
+	}
+
+	// styled images are refused while every render slot is busy
+	for i := 0; i < cap(app.renders); i++ {
+		app.renders <- struct{}{}
+	}
+	code, header, _ := ts.get(t, "/snippet/1/image.png?theme=dracula")
+	if code != http.StatusServiceUnavailable || header.Get("Retry-After") == "" {
+		t.Errorf("want %d with Retry-After; got %d", http.StatusServiceUnavailable, code)
+	}
+	if code, _, _ = ts.get(t, "/snippet/1/image.png"); code != http.StatusOK {
+		t.Errorf("want cached image served with busy slots; got %d", code)
+	}
+	code, header, _ = ts.get(t, "/snippet/9/image.png")
+	if code != http.StatusServiceUnavailable || header.Get("Retry-After") == "" {
+		t.Errorf("want uncached default image refused with %d; got %d", http.StatusServiceUnavailable, code)
+	}
+	for i := 0; i < cap(app.renders); i++ {
+		<-app.renders
+	}
+}
+
+func TestSnippetMeta(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name        string
+		urlPath     string
+		wantBody    []string
+		notWantBody []string
+	}{
+		{"Public", "/snippet/1", []string{
+			"<meta property='og:title' content='An old silent pond'>",
+			"<meta property='og:description' content='...'>",
+			"<meta property='og:image' content='" + ts.URL + "/snippet/1/card.png'>",
+			"<meta name='twitter:card' content='summary_large_image'>",
+		}, nil},
+		{"Markdown text", "/snippet/4", []string{
+			"<meta property='og:description' content='Notes write haiku'>",
+		}, nil},
+		{"Protected", "/snippet/7", []string{
+			"<meta property='og:description' content='Plain text · by Bob'>",
+			"<meta property='og:image' content='" + ts.URL + "/snippet/7/card.png'>",
+		}, []string{"The temple bell stops"}},
+		{"Other pages", "/user/login", nil, []string{"og:title"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, body := ts.get(t, tt.urlPath)
+
+			for _, want := range tt.wantBody {
+				if !bytes.Contains(body, []byte(want)) {
+					t.Errorf("want body to contain %q", want)
+				}
+			}
+			for _, notWant := range tt.notWantBody {
+				if bytes.Contains(body, []byte(notWant)) {
+					t.Errorf("want body not to contain %q", notWant)
+				}
+			}
+		})
+	}
+}
+
+func TestZipSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, header, body := ts.get(t, "/snippet/1/zip")
+	if code != http.StatusOK {
+		t.Fatalf("want %d; got %d", http.StatusOK, code)
+	}
+	if header.Get("Content-Type") != "application/zip" {
+		t.Errorf("want Content-Type %q; got %q", "application/zip", header.Get("Content-Type"))
+	}
+	want := "attachment; filename=an-old-silent-pond.zip"
+	if header.Get("Content-Disposition") != want {
+		t.Errorf("want Content-Disposition %q; got %q", want, header.Get("Content-Disposition"))
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"pond.txt", "frog.go"}) {
+		t.Errorf("want files [pond.txt frog.go]; got %v", names)
+	}
+
+	code, _, _ = ts.get(t, "/snippet/6/zip")
+	if code != http.StatusNotFound {
+		t.Errorf("want %d for private snippet; got %d", http.StatusNotFound, code)
+	}
+}
+
+func TestSnippetFiles(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/snippet/1")
+	for _, want := range []string{"pond.txt &middot;", "<strong>frog.go</strong>", "/snippet/1/raw?file=frog.go", "Download zip"} {
+		if !bytes.Contains(body, []byte(want)) {
+			t.Errorf("want body to contain %q", want)
+		}
+	}
+
+	csrfToken := ts.login(t)
+
+	tests := []struct {
+		name         string
+		filename     string
+		fileNames    []string
+		button       string
+		value        string
+		wantCode     int
+		wantLocation string
+		wantBody     []byte
+	}{
+		{"Valid", "main.go", []string{"util.go"}, "", "", http.StatusSeeOther, "/snippet/2", nil},
+		{"Add file", "", []string{"util.go"}, "add_file", "1", http.StatusOK, "", []byte("name='remove_file' value='1'")},
+		{"Remove file", "", []string{"util.go", "extra.go"}, "remove_file", "0", http.StatusOK, "", []byte("value='extra.go'")},
+		{"Missing main filename", "", []string{"util.go"}, "", "", http.StatusOK, "", []byte("This field cannot be blank")},
+		{"Missing file name", "main.go", []string{""}, "", "", http.StatusOK, "", []byte("File 1 needs a name")},
+		{"Invalid file name", "main.go", []string{"../etc"}, "", "", http.StatusOK, "", []byte("is invalid")},
+		{"Duplicate file name", "main.go", []string{"Main.go"}, "", "", http.StatusOK, "", []byte("is used more than once")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", "Title")
+			form.Add("content", "...")
+			form.Add("expires", "1w")
+			form.Add("filename", tt.filename)
+			for _, name := range tt.fileNames {
+				form.Add("file_name", name)
+				form.Add("file_language", "")
+				form.Add("file_content", "package main")
+			}
+			if tt.button != "" {
+				form.Add(tt.button, tt.value)
+			}
+			form.Add("csrf_token", csrfToken)
+
+			code, header, body := ts.postForm(t, "/snippet/create", form)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if loc := header.Get("Location"); loc != tt.wantLocation {
+				t.Errorf("want Location %q; got %q", tt.wantLocation, loc)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body %s to contain %q", body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestShowAttachment(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name            string
+		urlPath         string
+		wantCode        int
+		wantContentType string
+		wantDisposition string
+	}{
+		{"Image", "/snippet/1/attachments/1", http.StatusOK, "image/png", "inline; filename=frog.png"},
+		{"Image by slug", "/s/c2lsZW50LXBvbmQtc2x1Zw/attachments/1", http.StatusOK, "image/png", "inline; filename=frog.png"},
+		{"HTML", "/snippet/1/attachments/2", http.StatusOK, "text/html; charset=utf-8", "attachment; filename=page.html"},
+		{"Other snippet", "/snippet/4/attachments/1", http.StatusNotFound, "", ""},
+		{"Non-existent attachment", "/snippet/1/attachments/3", http.StatusNotFound, "", ""},
+		{"Private", "/snippet/6/attachments/1", http.StatusNotFound, "", ""},
+		{"Locked", "/snippet/7/attachments/1", http.StatusSeeOther, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, header, _ := ts.get(t, tt.urlPath)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if code != http.StatusOK {
+				return
+			}
+
+			if header.Get("Content-Type") != tt.wantContentType {
+				t.Errorf("want Content-Type %q; got %q", tt.wantContentType, header.Get("Content-Type"))
+			}
+			if header.Get("Content-Disposition") != tt.wantDisposition {
+				t.Errorf("want Content-Disposition %q; got %q", tt.wantDisposition, header.Get("Content-Disposition"))
+			}
+			if header.Get("X-Content-Type-Options") != "nosniff" {
+				t.Errorf("want X-Content-Type-Options nosniff; got %q", header.Get("X-Content-Type-Options"))
+			}
+		})
+	}
+
+	_, _, body := ts.get(t, "/snippet/1")
+	if !bytes.Contains(body, []byte("<a href='/snippet/1/attachments/1'>frog.png</a>")) {
+		t.Errorf("want snippet page to list its attachments")
+	}
+}
+
+func TestUploadAttachments(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	csrfToken := ts.login(t)
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		files        map[string]string
+		wantCode     int
+		wantLocation string
+		wantBody     []byte
+	}{
+		{"Valid", "/snippet/1/attachments", map[string]string{"build.log": "ok\n"}, http.StatusSeeOther, "/snippet/1/attachments", nil},
+		{"No files", "/snippet/1/attachments", nil, http.StatusOK, "", []byte("Choose at least one file")},
+		{"Empty file", "/snippet/1/attachments", map[string]string{"empty.txt": ""}, http.StatusOK, "", []byte("is empty")},
+		{"Too large", "/snippet/1/attachments", map[string]string{"big.bin": strings.Repeat("x", maxUploadSize)}, http.StatusRequestEntityTooLarge, "", nil},
+		{"Not owner", "/snippet/7/attachments", map[string]string{"build.log": "ok\n"}, http.StatusForbidden, "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields := url.Values{"csrf_token": {csrfToken}}
+			code, header, body := ts.postMultipart(t, tt.urlPath, fields, tt.files)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if loc := header.Get("Location"); loc != tt.wantLocation {
+				t.Errorf("want Location %q; got %q", tt.wantLocation, loc)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body %s to contain %q", body, tt.wantBody)
+			}
+		})
+	}
+
+	// deleting removes the blob
+	form := url.Values{"csrf_token": {csrfToken}}
+	code, _, _ := ts.postForm(t, "/snippet/1/attachments/1/delete", form)
+	if code != http.StatusSeeOther {
+		t.Errorf("want %d; got %d", http.StatusSeeOther, code)
+	}
+	if _, err := app.blobs.Get("attachments/mock-frog"); !errors.Is(err, blob.ErrNotFound) {
+		t.Errorf("want blob to be deleted; got %v", err)
+	}
+}
+
+func TestComments(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/snippet/1")
+	for _, want := range []string{"<div class='comment depth-0' id='comment-1'>", "<div class='comment depth-1' id='comment-2'>", "Log in</a> to comment"} {
+		if !bytes.Contains(body, []byte(want)) {
+			t.Errorf("want body to contain %q", want)
+		}
+	}
+
+	csrfToken := ts.login(t)
+
+	_, _, body = ts.get(t, "/snippet/1")
+	if !bytes.Contains(body, []byte("<a href='/comment/1/edit'>Edit</a>")) {
+		t.Errorf("want snippet owner to be able to edit comments")
+	}
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		content      string
+		parent       string
+		wantCode     int
+		wantLocation string
+		wantBody     []byte
+	}{
+		{"Comment", "/snippet/1/comments", "Nice!", "", http.StatusSeeOther, "/snippet/1#comment-4", nil},
+		{"Reply", "/snippet/1/comments", "Agreed", "1", http.StatusSeeOther, "/snippet/1#comment-4", nil},
+		{"Comment by slug", "/s/dW5saXN0ZWQtc25pcHBldA/comments", "Nice!", "", http.StatusSeeOther, "/s/dW5saXN0ZWQtc25pcHBldA#comment-4", nil},
+		{"Blank", "/snippet/1/comments", "", "", http.StatusOK, "", []byte("This field cannot be blank")},
+		{"Too long", "/snippet/1/comments", strings.Repeat("a", maxCommentLength+1), "", http.StatusOK, "", []byte("This field is too long")},
+		{"Reply on other snippet", "/snippet/4/comments", "Agreed", "1", http.StatusBadRequest, "", nil},
+		{"Invalid parent", "/snippet/1/comments", "Agreed", "blah", http.StatusBadRequest, "", nil},
+		{"Private", "/snippet/6/comments", "Nice!", "", http.StatusSeeOther, "/snippet/6#comment-4", nil},
+		{"Locked", "/snippet/7/comments", "Nice!", "", http.StatusSeeOther, "/snippet/7", nil},
+		{"Edit own", "/comment/2/edit", "Fixed typo", "", http.StatusSeeOther, "/snippet/1#comment-2", nil},
+		{"Edit as snippet owner", "/comment/1/edit", "Moderated", "", http.StatusSeeOther, "/snippet/1#comment-1", nil},
+		{"Edit blank", "/comment/2/edit", "", "", http.StatusOK, "", []byte("This field cannot be blank")},
+		{"Edit other", "/comment/3/edit", "Mine now", "", http.StatusForbidden, "", nil},
+		{"Delete", "/comment/2/delete", "", "", http.StatusSeeOther, "/snippet/1#comments", nil},
+		{"Delete other", "/comment/3/delete", "", "", http.StatusForbidden, "", nil},
+		{"Delete non-existent", "/comment/9/delete", "", "", http.StatusNotFound, "", nil},
+		{"Edit own on hidden snippet", "/comment/4/edit", "Still mine", "", http.StatusNotFound, "", nil},
+		{"Delete own on hidden snippet", "/comment/4/delete", "", "", http.StatusNotFound, "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("content", tt.content)
+			form.Add("parent", tt.parent)
+			form.Add("csrf_token", csrfToken)
+
+			code, header, body := ts.postForm(t, tt.urlPath, form)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if loc := header.Get("Location"); loc != tt.wantLocation {
+				t.Errorf("want Location %q; got %q", tt.wantLocation, loc)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body %s to contain %q", body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestStars(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, header, _ := ts.get(t, "/user/starred")
+	if code != http.StatusSeeOther || header.Get("Location") != "/user/login" {
+		t.Errorf("want starred page to require login; got %d %q", code, header.Get("Location"))
+	}
+
+	_, _, body := ts.get(t, "/trending")
+	if !bytes.Contains(body, []byte("An old silent pond")) || !bytes.Contains(body, []byte("&#9733; 3")) {
+		t.Errorf("want trending page to list snippets with their stars")
+	}
+
+	csrfToken := ts.login(t)
+
+	_, _, body = ts.get(t, "/snippet/1")
+	if !bytes.Contains(body, []byte("<form action='/snippet/1/unstar' method='POST'>")) {
+		t.Errorf("want starred snippet to offer unstarring")
+	}
+	_, _, body = ts.get(t, "/snippet/4")
+	if !bytes.Contains(body, []byte("<form action='/snippet/4/star' method='POST'>")) {
+		t.Errorf("want snippet to offer starring")
+	}
+
+	_, _, body = ts.get(t, "/user/starred")
+	if !bytes.Contains(body, []byte("<a href='/snippet/1'>An old silent pond</a>")) {
+		t.Errorf("want starred page to link available snippets")
+	}
+	if !bytes.Contains(body, []byte("A vanished haiku <span class='unavailable'>(no longer available)</span>")) {
+		t.Errorf("want starred page to keep snippets that are gone")
+	}
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		wantCode     int
+		wantLocation string
+	}{
+		{"Star", "/snippet/4/star", http.StatusSeeOther, "/snippet/4"},
+		{"Star by slug", "/s/dW5saXN0ZWQtc25pcHBldA/star", http.StatusSeeOther, "/s/dW5saXN0ZWQtc25pcHBldA"},
+		{"Star locked", "/snippet/7/star", http.StatusSeeOther, "/snippet/7"},
+		{"Star non-existent", "/snippet/2/star", http.StatusNotFound, ""},
+		{"Unstar", "/snippet/1/unstar", http.StatusSeeOther, "/snippet/1"},
+		{"Remove gone snippet", "/user/starred/2/remove", http.StatusSeeOther, "/user/starred"},
+		{"Remove invalid", "/user/starred/blah/remove", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("csrf_token", csrfToken)
+
+			code, header, _ := ts.postForm(t, tt.urlPath, form)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if loc := header.Get("Location"); loc != tt.wantLocation {
+				t.Errorf("want Location %q; got %q", tt.wantLocation, loc)
+			}
+		})
+	}
+}
+
+func TestSnippetStats(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// viewing a snippet counts without saving straight away
+	ts.get(t, "/snippet/1")
+	if batch := app.viewCounter.take(); len(batch.Views) != 1 {
+		t.Errorf("want view to be counted")
+	}
+
+	ts.login(t)
+
+	// owners aren't counted
+	ts.get(t, "/snippet/1")
+	if batch := app.viewCounter.take(); len(batch.Views) != 0 {
+		t.Errorf("want owner view not to be counted")
+	}
+
+	code, _, body := ts.get(t, "/snippet/1/stats")
+	if code != http.StatusOK {
+		t.Fatalf("want %d; got %d", http.StatusOK, code)
+	}
+	for _, want := range []string{"16 views in total, 8 unique", "https://news.example.com/item", "width: 100%"} {
+		if !bytes.Contains(body, []byte(want)) {
+			t.Errorf("want body to contain %q", want)
+		}
+	}
+
+	code, _, _ = ts.get(t, "/snippet/7/stats")
+	if code != http.StatusForbidden {
+		t.Errorf("want %d; got %d", http.StatusForbidden, code)
+	}
+}
+
+func TestForkSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/snippet/1")
+	if !bytes.Contains(body, []byte("An old silent pond (remix)")) {
+		t.Errorf("want original to list its forks")
+	}
+
+	_, _, body = ts.get(t, "/snippet/9")
+	if !bytes.Contains(body, []byte("forked from <a href='/snippet/1'>#1</a>")) {
+		t.Errorf("want fork to link to its parent")
+	}
+
+	code, header, _ := ts.get(t, "/snippet/1/fork")
+	if code != http.StatusSeeOther || header.Get("Location") != "/user/login" {
+		t.Errorf("want redirect to login; got %d %q", code, header.Get("Location"))
+	}
+
+	csrfToken := ts.login(t)
+
+	code, _, body = ts.get(t, "/snippet/1/fork")
+	if code != http.StatusOK {
+		t.Fatalf("want %d; got %d", http.StatusOK, code)
+	}
+	for _, want := range []string{"value='An old silent pond'", "name='parent' value='c2lsZW50LXBvbmQtc2x1Zw'"} {
+		if !bytes.Contains(body, []byte(want)) {
+			t.Errorf("want body to contain %q", want)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		parent   string
+		wantCode int
+	}{
+		{"Valid", "c2lsZW50LXBvbmQtc2x1Zw", http.StatusSeeOther},
+		{"Unlisted parent", "dW5saXN0ZWQtc25pcHBldA", http.StatusSeeOther},
+		{"Non-existent parent", "blah", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", "Fork")
+			form.Add("content", "...")
+			form.Add("expires", "1w")
+			form.Add("parent", tt.parent)
+			form.Add("csrf_token", csrfToken)
+
+			code, _, _ := ts.postForm(t, "/snippet/create", form)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+		})
+	}
+}
@@ -3,17 +3,99 @@ package main
 import (
 	"errors"
 	"fmt"
+	"mime"
 	"net/http"
+	"net/url"
+	"path"
 	"strconv"
+	"strings"
 
+	"robert-tu.net/snippetbox/pkg/diff"
 	"robert-tu.net/snippetbox/pkg/forms"
+	"robert-tu.net/snippetbox/pkg/highlight"
+	"robert-tu.net/snippetbox/pkg/markdown"
 	"robert-tu.net/snippetbox/pkg/models"
 )
 
 // home handler function
 // writes byte slice as the response body
 func (app *application) home(w http.ResponseWriter, r *http.Request) {
-	s, err := app.snippets.Latest()
+	app.listSnippets(w, r)
+}
+
+// tagSnippets handler lists snippets with the :name tag, a page at a time
+func (app *application) tagSnippets(w http.ResponseWriter, r *http.Request) {
+	tag := r.URL.Query().Get(":name")
+	page, err := queryInt(r, "page", 1)
+	if err != nil || page < 1 {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	// fetch one extra snippet to find out if there is another page
+	s, err := app.snippets.ByTag(tag, (page-1)*defaultPageSize, defaultPageSize+1)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// pat unescapes parameters as query strings
+	base := "/tag/" + url.QueryEscape(tag)
+	p := &pagination{}
+	if page > 1 {
+		p.PrevURL = fmt.Sprintf("%s?page=%d", base, page-1)
+	}
+	if len(s) > defaultPageSize {
+		s = s[:defaultPageSize]
+		p.NextURL = fmt.Sprintf("%s?page=%d", base, page+1)
+	}
+
+	app.render(w, r, "tag.page.tmpl", &templateData{
+		Snippets:   s,
+		Pagination: p,
+		Tag:        tag,
+	})
+}
+
+// listSnippets handler shows a page of the latest snippets
+// accepts before or after cursors and a page size in limit
+func (app *application) listSnippets(w http.ResponseWriter, r *http.Request) {
+	limit, err := queryInt(r, "limit", defaultPageSize)
+	if err != nil || limit < 1 || limit > maxPageSize {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	var before, after *models.Cursor
+	if token := r.URL.Query().Get("before"); token != "" {
+		before, err = models.ParseCursor(token)
+	} else if token := r.URL.Query().Get("after"); token != "" {
+		after, err = models.ParseCursor(token)
+	}
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	p, err := app.snippets.Page(before, after, limit)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// links keep the page size if it was changed
+	link := func(key string, c *models.Cursor) string {
+		if c == nil {
+			return ""
+		}
+		q := url.Values{key: {c.String()}}
+		if limit != defaultPageSize {
+			q.Set("limit", strconv.Itoa(limit))
+		}
+		return "/snippets?" + q.Encode()
+	}
+
+	tags, err := app.tags.Cloud(tagCloudSize)
 	if err != nil {
 		app.serverError(w, err)
 		return
@@ -21,20 +103,95 @@ func (app *application) home(w http.ResponseWriter, r *http.Request) {
 
 	// use render
 	app.render(w, r, "home.page.tmpl", &templateData{
-		Snippets: s,
+		Snippets: p.Snippets,
+		TagCloud: newTagCloud(tags),
+		Pagination: &pagination{
+			PrevURL: link("after", p.Newer),
+			NextURL: link("before", p.Older),
+		},
+	})
+}
+
+// search handler shows snippets matching the q parameter, a page at a time
+func (app *application) search(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	page, err := queryInt(r, "page", 1)
+	if err != nil || page < 1 {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	// show just the search form without a query
+	if query == "" {
+		app.render(w, r, "search.page.tmpl", nil)
+		return
+	}
+
+	// fetch one extra result to find out if there is another page
+	s, err := app.snippets.Search(query, (page-1)*defaultPageSize, defaultPageSize+1)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	p := &pagination{}
+	if page > 1 {
+		p.PrevURL = "/search?" + url.Values{"q": {query}, "page": {strconv.Itoa(page - 1)}}.Encode()
+	}
+	if len(s) > defaultPageSize {
+		s = s[:defaultPageSize]
+		p.NextURL = "/search?" + url.Values{"q": {query}, "page": {strconv.Itoa(page + 1)}}.Encode()
+	}
+
+	app.render(w, r, "search.page.tmpl", &templateData{
+		Snippets:   s,
+		Pagination: p,
+		Query:      query,
 	})
 }
 
 // showSnippet handler function
 func (app *application) showSnippet(w http.ResponseWriter, r *http.Request) {
-	// extract id and convert to int
-	id, err := strconv.Atoi(r.URL.Query().Get(":id"))
-	if err != nil || id < 1 {
-		app.notFound(w)
+	s, ok := app.snippetFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	// ask for the passphrase before showing anything of a protected snippet
+	if !app.unlocked(r, s) {
+		app.render(w, r, "unlock.page.tmpl", &templateData{
+			Snippet:   s,
+			Permalink: snippetURL(s),
+			Meta:      app.snippetMeta(r, s),
+			Form:      forms.New(nil),
+		})
+		return
+	}
+
+	// views are only used up by confirming, so link previews and crawlers can't burn a snippet
+	if s.ViewLimit && s.UserID != app.authenticatedUserID(r) {
+		app.render(w, r, "reveal.page.tmpl", &templateData{
+			Snippet:   s,
+			Permalink: snippetURL(s),
+			Meta:      app.snippetMeta(r, s),
+		})
 		return
 	}
+	app.renderSnippet(w, r, s)
+}
 
-	s, err := app.snippets.Get(id)
+// revealSnippet handler shows a view limited snippet, using up one of its views
+func (app *application) revealSnippet(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.snippetFromRequest(w, r)
+	if !ok {
+		return
+	}
+	if !app.unlocked(r, s) {
+		http.Redirect(w, r, snippetURL(s), http.StatusSeeOther)
+		return
+	}
+
+	s, err := app.snippets.Read(s.ID)
 	if err != nil {
 		if errors.Is(err, models.ErrNoRecord) {
 			app.notFound(w)
@@ -44,17 +201,260 @@ func (app *application) showSnippet(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// the content can't be fetched again, keep it out of caches
+	w.Header().Set("Cache-Control", "no-store")
+	app.renderSnippet(w, r, s)
+}
+
+// unlockSnippet handler checks the passphrase of a protected snippet
+// and remembers it in the session so the reader isn't asked again
+func (app *application) unlockSnippet(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.snippetFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	err = app.snippets.Unlock(s.ID, form.Get("passphrase"))
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			form.Errors.Add("generic", "Passphrase is incorrect")
+			app.render(w, r, "unlock.page.tmpl", &templateData{
+				Snippet:   s,
+				Permalink: snippetURL(s),
+				Form:      form,
+			})
+		} else if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.session.Put(r, unlockKey(s.ID), true)
+
+	http.Redirect(w, r, snippetURL(s), http.StatusSeeOther)
+}
+
+// renderSnippet shows the content of a snippet, highlighted or rendered as markdown
+func (app *application) renderSnippet(w http.ResponseWriter, r *http.Request, s *models.Snippet) {
+	tags, err := app.tags.ForSnippet(s.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	s.Tags = tags
+
+	s.Files, err = app.files.ForSnippet(s.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// owners looking at their own snippets aren't counted
+	if s.UserID != app.authenticatedUserID(r) {
+		app.viewCounter.record(r, s.ID)
+	}
+
+	td := &templateData{
+		Snippet:   s,
+		Permalink: snippetURL(s),
+		Meta:      app.snippetMeta(r, s),
+	}
+
+	if embeddable(s) {
+		td.EmbedURL = app.absoluteURL(r, snippetURL(s)+"/embed")
+		td.OEmbedURL = app.absoluteURL(r, "/oembed?url="+url.QueryEscape(app.absoluteURL(r, snippetURL(s))))
+	}
+
+	td.Attachments, err = app.attachments.ForSnippet(s.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	comments, err := app.comments.ForSnippet(s.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	td.Comments = threadComments(comments, app.authenticatedUserID(r), s.UserID)
+
+	if app.isAuthenticated(r) {
+		td.Starred, err = app.stars.Starred(app.authenticatedUserID(r), s.ID)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
+
+	// the parent is only linked if the user could open it
+	if s.ParentID != 0 {
+		parent, err := app.snippets.Get(s.ParentID)
+		if err != nil && !errors.Is(err, models.ErrNoRecord) {
+			app.serverError(w, err)
+			return
+		}
+		if err == nil && app.canView(r, parent, false) {
+			td.Parent = parent
+		}
+	}
+
+	td.Snippets, err = app.snippets.Forks(s.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	for _, f := range s.Files {
+		view, err := newFileView(f)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		td.Files = append(td.Files, view)
+	}
+
+	// markdown is rendered unless the source was asked for
+	if s.Language == highlight.Markdown && r.URL.Query().Get("view") != "source" {
+		td.Rendered, err = markdown.Render(s.Content)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		app.render(w, r, "show.page.tmpl", td)
+		return
+	}
+
+	lines, err := highlight.Lines(s.Content, s.Language)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	first, last := parseLineRange(r.URL.Query().Get("lines"))
+	td.Lines = newCodeLines(lines, first, last)
+
 	// use render
-	app.render(w, r, "show.page.tmpl", &templateData{
-		Snippet: s,
+	app.render(w, r, "show.page.tmpl", td)
+}
+
+// forkSnippet handler opens the create form pre-filled with a copy of a snippet
+func (app *application) forkSnippet(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.snippetFromRequest(w, r)
+	if !ok || !app.requireReadable(w, r, s) {
+		return
+	}
+
+	tags, err := app.tags.ForSnippet(s.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	files, err := app.files.ForSnippet(s.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	fields := make([]fileField, len(files))
+	for i, f := range files {
+		fields[i] = fileField{Index: i, Name: f.Name, Language: f.Language, Content: f.Content}
+	}
+
+	// the parent is named by its slug so forking can't be used to probe for unlisted snippets
+	app.render(w, r, "create.page.tmpl", &templateData{
+		Parent: s,
+		Form: forms.New(url.Values{
+			"title":    {s.Title},
+			"content":  {s.Content},
+			"language": {s.Language},
+			"filename": {s.Filename},
+			"tags":     {strings.Join(tags, ", ")},
+			"parent":   {s.Slug},
+		}),
+		FileFields:    fields,
+		Languages:     highlight.Languages,
+		ExpiryPresets: app.expiryPresets,
 	})
 }
 
+// rawSnippet handler returns the content of a snippet, or of the file named by ?file=, as plain text
+func (app *application) rawSnippet(w http.ResponseWriter, r *http.Request) {
+	s, f, ok := app.fileFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	writeRaw(w, s, f)
+}
+
+// downloadSnippet handler returns the content of a snippet, or of the file named by ?file=, as an attachment
+func (app *application) downloadSnippet(w http.ResponseWriter, r *http.Request) {
+	s, f, ok := app.fileFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": f.Name}))
+	writeRaw(w, s, f)
+}
+
+// zipSnippet handler returns every file of a snippet in a zip archive
+func (app *application) zipSnippet(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.snippetFromRequest(w, r)
+	if !ok || !app.requireReadable(w, r, s) {
+		return
+	}
+
+	files, err := app.files.ForSnippet(s.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	s.Files = files
+
+	archive, err := zipFiles(snippetFiles(s))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	name := strings.TrimSuffix(snippetFilename(s), path.Ext(snippetFilename(s))) + ".zip"
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	noStore(w, s)
+	w.Write(archive)
+}
+
+// writeRaw writes the content of a file as plain text that browsers won't sniff or cache
+func writeRaw(w http.ResponseWriter, s *models.Snippet, f *models.File) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	noStore(w, s)
+	w.Write([]byte(f.Content))
+}
+
+// noStore stops shared caches keeping snippets that aren't public
+func noStore(w http.ResponseWriter, s *models.Snippet) {
+	if s.Protected || s.Visibility != models.Public {
+		w.Header().Set("Cache-Control", "private, no-store")
+	}
+}
+
 // createSnippetForm handler function
 func (app *application) createSnippetForm(w http.ResponseWriter, r *http.Request) {
 	app.render(w, r, "create.page.tmpl", &templateData{
 		// pass new empty forms.Form
-		Form: forms.New(nil),
+		Form:          forms.New(nil),
+		Languages:     highlight.Languages,
+		ExpiryPresets: app.expiryPresets,
 	})
 }
 
@@ -69,20 +469,85 @@ func (app *application) createSnippet(w http.ResponseWriter, r *http.Request) {
 	// data validation
 	// create new forms.Form struct with posted data
 	form := forms.New(r.PostForm)
+
+	// adding or removing a file shows the form again without validating it
+	fields, edited := editFileFields(form, fileFields(form))
+	if edited {
+		app.render(w, r, "create.page.tmpl", &templateData{
+			Form:          form,
+			FileFields:    fields,
+			Languages:     highlight.Languages,
+			ExpiryPresets: app.expiryPresets,
+		})
+		return
+	}
+
 	form.Require("title", "content", "expires")
 	form.MaxLength("title", 100)
-	form.PermittedValues("expires", "365", "7", "1")
+	form.MaxLength("filename", 255)
+	form.MatchesPattern("filename", validFilenameRX)
+	files := validFiles(form, fields)
+	expires := app.validExpiry(r, form)
+	form.PermittedValues("language", highlight.Names()...)
+	form.PermittedValues("visibility", models.Public, models.Unlisted, models.Private)
+	form.MinLength("passphrase", minPassphraseLength)
+	form.IntRange("max_views", 1, maxViewLimit)
+	form.ValidTags("tags", maxTags, maxTagLength)
+
+	// snippet being forked, if any
+	var parent *models.Snippet
+	if slug := form.Get("parent"); slug != "" {
+		parent, err = app.snippets.GetBySlug(slug)
+		if errors.Is(err, models.ErrNoRecord) || (err == nil && !app.canView(r, parent, true)) {
+			app.clientError(w, http.StatusBadRequest)
+			return
+		} else if err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
 
 	// display error messages
 	if !form.Valid() {
 		app.render(w, r, "create.page.tmpl", &templateData{
-			Form: form,
+			Parent:        parent,
+			Form:          form,
+			FileFields:    fields,
+			Languages:     highlight.Languages,
+			ExpiryPresets: app.expiryPresets,
 		})
 		return
 	}
 
+	// guess language when left blank
+	language := form.Get("language")
+	if language == "" {
+		language = guessLanguage(form.Get("filename"), form.Get("content"))
+	}
+
 	// retrieve validated values with Get()
-	id, err := app.snippets.Insert(form.Get("title"), form.Get("content"), form.Get("expires"))
+	// snippet is owned by the logged in user
+	s := &models.Snippet{
+		UserID:     app.authenticatedUserID(r),
+		Title:      form.Get("title"),
+		Content:    form.Get("content"),
+		Language:   language,
+		Filename:   form.Get("filename"),
+		Visibility: visibility(form),
+		Expires:    expires,
+		Tags:       form.Tags("tags"),
+		Files:      files,
+	}
+	if parent != nil {
+		s.ParentID = parent.ID
+	}
+	// blank means no view limit
+	if maxViews, _ := strconv.Atoi(form.Get("max_views")); maxViews > 0 {
+		s.ViewLimit = true
+		s.ViewsLeft = maxViews
+	}
+
+	id, err := app.snippets.Insert(s, form.Get("passphrase"))
 	if err != nil {
 		app.serverError(w, err)
 		return
@@ -95,6 +560,380 @@ func (app *application) createSnippet(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, fmt.Sprintf("/snippet/%d", id), http.StatusSeeOther)
 }
 
+// editSnippetForm handler shows the current version of a snippet to its owner
+func (app *application) editSnippetForm(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	tags, err := app.tags.ForSnippet(s.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// pre-fill form with current values
+	app.render(w, r, "edit.page.tmpl", &templateData{
+		Snippet: s,
+		Form: forms.New(url.Values{
+			"title":      {s.Title},
+			"content":    {s.Content},
+			"language":   {s.Language},
+			"visibility": {s.Visibility},
+			"tags":       {strings.Join(tags, ", ")},
+			"version":    {strconv.Itoa(s.Version)},
+		}),
+		Languages: highlight.Languages,
+	})
+}
+
+// editSnippet handler saves a new revision of a snippet
+func (app *application) editSnippet(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Require("title", "content", "version")
+	form.MaxLength("title", 100)
+	form.PermittedValues("language", highlight.Names()...)
+	form.PermittedValues("visibility", models.Public, models.Unlisted, models.Private)
+	form.MinLength("passphrase", minPassphraseLength)
+	form.ValidTags("tags", maxTags, maxTagLength)
+	// version the form was loaded from
+	version, err := strconv.Atoi(form.Get("version"))
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	if !form.Valid() {
+		app.render(w, r, "edit.page.tmpl", &templateData{
+			Snippet:   s,
+			Form:      form,
+			Languages: highlight.Languages,
+		})
+		return
+	}
+
+	// guess language when left blank, the same way as when the snippet was created
+	language := form.Get("language")
+	if language == "" {
+		language = guessLanguage(s.Filename, form.Get("content"))
+	}
+
+	err = app.snippets.Update(s.ID, app.authenticatedUserID(r), version, form.Get("title"), form.Get("content"), language, visibility(form), form.Tags("tags"))
+	if err != nil {
+		if errors.Is(err, models.ErrEditConflict) {
+			// keep the draft but base it on the latest version so it can be resubmitted after review
+			form.Errors.Add("generic", "This snippet was changed while you were editing it. Review the current version below before saving again.")
+			form.Set("version", strconv.Itoa(s.Version))
+			app.render(w, r, "edit.page.tmpl", &templateData{
+				Snippet:   s,
+				Form:      form,
+				Languages: highlight.Languages,
+			})
+		} else if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	// a blank passphrase keeps the current one
+	switch {
+	case form.Get("remove_passphrase") != "":
+		err = app.snippets.SetPassphrase(s.ID, s.UserID, "")
+	case form.Get("passphrase") != "":
+		err = app.snippets.SetPassphrase(s.ID, s.UserID, form.Get("passphrase"))
+	}
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.session.Put(r, "flash", "Snippet updated successfully!")
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/%d", s.ID), http.StatusSeeOther)
+}
+
+// expiryForm handler lets the owner extend or shorten the life of a snippet
+func (app *application) expiryForm(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	app.render(w, r, "expiry.page.tmpl", &templateData{
+		Snippet:       s,
+		Form:          forms.New(nil),
+		ExpiryPresets: app.expiryPresets,
+	})
+}
+
+// updateExpiry handler sets a new expiry time on a snippet
+func (app *application) updateExpiry(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Require("expires")
+	expires := app.validExpiry(r, form)
+
+	if !form.Valid() {
+		app.render(w, r, "expiry.page.tmpl", &templateData{
+			Snippet:       s,
+			Form:          form,
+			ExpiryPresets: app.expiryPresets,
+		})
+		return
+	}
+
+	err = app.snippets.SetExpires(s.ID, s.UserID, expires)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.session.Put(r, "flash", "Snippet expiry updated successfully!")
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/%d", s.ID), http.StatusSeeOther)
+}
+
+// snippetHistory handler lists the revisions of a snippet
+func (app *application) snippetHistory(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.snippetFromRequest(w, r)
+	if !ok || !app.requireReadable(w, r, s) {
+		return
+	}
+
+	revisions, err := app.snippets.Revisions(s.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.render(w, r, "history.page.tmpl", &templateData{
+		Snippet:   s,
+		Revisions: revisions,
+	})
+}
+
+// snippetDiff handler compares two revisions of a snippet
+// defaults to the changes made by the latest revision
+func (app *application) snippetDiff(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.snippetFromRequest(w, r)
+	if !ok || !app.requireReadable(w, r, s) {
+		return
+	}
+
+	// parse optional from and to versions
+	to, err := queryInt(r, "to", s.Version)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	from, err := queryInt(r, "from", to-1)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	if from < 1 {
+		from = 1
+	}
+
+	a, err := app.snippets.Revision(s.ID, from)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+	b, err := app.snippets.Revision(s.ID, to)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.renderDiff(w, r, s,
+		fmt.Sprintf("snippet-%d-v%d", s.ID, from), a.Content,
+		fmt.Sprintf("snippet-%d-v%d", s.ID, to), b.Content,
+		fmt.Sprintf("%s/diff?from=%d&to=%d", snippetURL(s), from, to))
+}
+
+// compareSnippets returns a handler comparing the content of two different snippets
+// named by their IDs or, if bySlug is set, by their slugs so unlisted snippets can be compared
+func (app *application) compareSnippets(bySlug bool) http.HandlerFunc {
+	find := app.snippetFromParam
+	if bySlug {
+		find = app.snippetFromSlug
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		a, ok := find(w, r, ":a")
+		if !ok || !app.requireReadable(w, r, a) {
+			return
+		}
+		b, ok := find(w, r, ":b")
+		if !ok || !app.requireReadable(w, r, b) {
+			return
+		}
+
+		baseURL := fmt.Sprintf("/compare/%d/%d?", a.ID, b.ID)
+		if bySlug {
+			baseURL = fmt.Sprintf("/compare/s/%s/%s?", a.Slug, b.Slug)
+		}
+		app.renderDiff(w, r, nil,
+			fmt.Sprintf("snippet-%d", a.ID), a.Content,
+			fmt.Sprintf("snippet-%d", b.ID), b.Content,
+			baseURL)
+	}
+}
+
+// renderDiff writes a diff as a page or, with format=patch, as a patch download
+// baseURL is the page URL without view or format parameters
+func (app *application) renderDiff(w http.ResponseWriter, r *http.Request, s *models.Snippet, fromName, a, toName, b, baseURL string) {
+	sep := "&"
+	if strings.HasSuffix(baseURL, "?") {
+		sep = ""
+	}
+
+	// skip diffing texts whose edit script would be too costly to compute
+	if diff.TooLarge(a, b) {
+		if r.URL.Query().Get("format") == "patch" {
+			app.clientError(w, http.StatusUnprocessableEntity)
+			return
+		}
+		app.render(w, r, "diff.page.tmpl", &templateData{
+			Snippet: s,
+			Diff: &diffData{
+				From:     fromName,
+				To:       toName,
+				TooLarge: true,
+			},
+		})
+		return
+	}
+
+	lines := diff.Lines(a, b)
+
+	if r.URL.Query().Get("format") == "patch" {
+		w.Header().Set("Content-Type", "text/x-diff; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fromName+"-"+toName+".patch"))
+		w.Write([]byte(diff.Unified(fromName, toName, lines)))
+		return
+	}
+
+	app.render(w, r, "diff.page.tmpl", &templateData{
+		Snippet: s,
+		Diff: &diffData{
+			From:     fromName,
+			To:       toName,
+			Hunks:    diff.Hunks(lines, 3),
+			Rows:     diff.SideBySide(lines),
+			Split:    r.URL.Query().Get("view") == "split",
+			Changed:  diff.Changed(lines),
+			BaseURL:  baseURL + sep,
+			PatchURL: baseURL + sep + "format=patch",
+		},
+	})
+}
+
+// deleteSnippet handler moves a snippet to its owner's trash
+func (app *application) deleteSnippet(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.snippets.Delete(s.ID, s.UserID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.session.Put(r, "flash", "Snippet moved to the trash. It can be restored for 30 days.")
+
+	http.Redirect(w, r, "/user/trash", http.StatusSeeOther)
+}
+
+// restoreSnippet handler takes a snippet back out of the trash
+func (app *application) restoreSnippet(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Query().Get(":id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	// only matches snippets in the trash of the logged in user
+	err = app.snippets.Restore(id, app.authenticatedUserID(r))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.session.Put(r, "flash", "Snippet restored successfully!")
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/%d", id), http.StatusSeeOther)
+}
+
+// trash handler lists the deleted snippets of the logged in user
+func (app *application) trash(w http.ResponseWriter, r *http.Request) {
+	s, err := app.snippets.Trash(app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.render(w, r, "trash.page.tmpl", &templateData{
+		Snippets: s,
+	})
+}
+
+// userSnippets handler lists snippets owned by the logged in user
+func (app *application) userSnippets(w http.ResponseWriter, r *http.Request) {
+	s, err := app.snippets.ByUser(app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.render(w, r, "mysnippets.page.tmpl", &templateData{
+		Snippets: s,
+	})
+}
+
 func (app *application) signupUserForm(w http.ResponseWriter, r *http.Request) {
 	app.render(w, r, "signup.page.tmpl", &templateData{
 		Form: forms.New(nil),
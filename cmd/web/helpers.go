@@ -2,12 +2,19 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"net/http"
+	"regexp"
 	"runtime/debug"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/justinas/nosurf"
+	"robert-tu.net/snippetbox/pkg/forms"
+	"robert-tu.net/snippetbox/pkg/highlight"
+	"robert-tu.net/snippetbox/pkg/models"
 )
 
 // serverError helper writes error message and stack trace to errorLog
@@ -40,11 +47,17 @@ func (app *application) addDefaultData(td *templateData, r *http.Request) *templ
 	td.Flash = app.session.PopString(r, "flash")
 	// check authentication status
 	td.IsAuthenticated = app.isAuthenticated(r)
+	td.AuthenticatedUserID = app.authenticatedUserID(r)
 	return td
 }
 
 // render helper for templates
 func (app *application) render(w http.ResponseWriter, r *http.Request, name string, td *templateData) {
+	app.renderTemplate(w, name, app.addDefaultData(td, r))
+}
+
+// renderTemplate helper executes a template as is, for pages served without a session
+func (app *application) renderTemplate(w http.ResponseWriter, name string, td *templateData) {
 	// retrieve appropriate template set from cache based on page name
 	ts, ok := app.templateCache[name]
 	if !ok {
@@ -54,7 +67,7 @@ func (app *application) render(w http.ResponseWriter, r *http.Request, name stri
 	// initialize new buffer
 	buf := new(bytes.Buffer)
 	// write template to buffer
-	err := ts.Execute(buf, app.addDefaultData(td, r))
+	err := ts.Execute(buf, td)
 	if err != nil {
 		app.serverError(w, err)
 		return
@@ -71,3 +84,168 @@ func (app *application) isAuthenticated(r *http.Request) bool {
 	}
 	return isAuthenticated
 }
+
+// return ID of the logged in user, or 0 if request is not authenticated
+func (app *application) authenticatedUserID(r *http.Request) int {
+	if !app.isAuthenticated(r) {
+		return 0
+	}
+	return app.session.GetInt(r, "authenticatedUserID")
+}
+
+// snippetFromRequest helper fetches the snippet named by the :slug or :id parameter
+// writes an error response and returns false if it can't be shown
+func (app *application) snippetFromRequest(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	if r.URL.Query().Get(":slug") != "" {
+		return app.snippetFromSlug(w, r, ":slug")
+	}
+	return app.snippetFromParam(w, r, ":id")
+}
+
+// snippetFromParam helper fetches the snippet whose ID is in the given URL parameter
+func (app *application) snippetFromParam(w http.ResponseWriter, r *http.Request, param string) (*models.Snippet, bool) {
+	// extract id and convert to int
+	id, err := strconv.Atoi(r.URL.Query().Get(param))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return nil, false
+	}
+
+	s, err := app.snippets.Get(id)
+	return app.visibleSnippet(w, r, s, err, false)
+}
+
+// snippetFromSlug helper fetches the snippet whose slug is in the given URL parameter
+func (app *application) snippetFromSlug(w http.ResponseWriter, r *http.Request, param string) (*models.Snippet, bool) {
+	s, err := app.snippets.GetBySlug(r.URL.Query().Get(param))
+	return app.visibleSnippet(w, r, s, err, true)
+}
+
+// visibleSnippet helper checks the result of a lookup and whether the user may see it
+// unlisted snippets are only found through their slug and private ones only by their owner,
+// anyone else gets a 404 so the snippet's existence isn't revealed
+func (app *application) visibleSnippet(w http.ResponseWriter, r *http.Request, s *models.Snippet, err error, bySlug bool) (*models.Snippet, bool) {
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return nil, false
+	}
+
+	if !app.canView(r, s, bySlug) {
+		app.notFound(w)
+		return nil, false
+	}
+	return s, true
+}
+
+// canView reports whether the user may see a snippet found by ID or, if bySlug is set, by slug
+func (app *application) canView(r *http.Request, s *models.Snippet, bySlug bool) bool {
+	switch s.Visibility {
+	case models.Public:
+		return true
+	case models.Unlisted:
+		return bySlug || s.UserID == app.authenticatedUserID(r)
+	default:
+		return s.UserID == app.authenticatedUserID(r)
+	}
+}
+
+// unlocked reports whether the user may read the content of a snippet
+// owners never need the passphrase, readers unlock a snippet once per session
+func (app *application) unlocked(r *http.Request, s *models.Snippet) bool {
+	return !s.Protected || s.UserID == app.authenticatedUserID(r) || app.session.GetBool(r, unlockKey(s.ID))
+}
+
+// requireReadable helper redirects to the snippet page if its content can't be shown directly,
+// either because it is still locked or because each view of it has to be confirmed
+func (app *application) requireReadable(w http.ResponseWriter, r *http.Request, s *models.Snippet) bool {
+	if !app.unlocked(r, s) || (s.ViewLimit && s.UserID != app.authenticatedUserID(r)) {
+		http.Redirect(w, r, snippetURL(s), http.StatusSeeOther)
+		return false
+	}
+	return true
+}
+
+// unlockKey returns the session key remembering an unlocked snippet
+func unlockKey(id int) string {
+	return fmt.Sprintf("unlocked:%d", id)
+}
+
+// snippetURL returns the link to a snippet, unlisted snippets are linked by slug
+func snippetURL(s *models.Snippet) string {
+	if s.Visibility == models.Unlisted {
+		return "/s/" + s.Slug
+	}
+	return fmt.Sprintf("/snippet/%d", s.ID)
+}
+
+// absoluteURL prefixes a path with the address of the site, for links followed from elsewhere
+func (app *application) absoluteURL(r *http.Request, path string) string {
+	if app.baseURL != "" {
+		return app.baseURL + path
+	}
+	// the server only listens over TLS
+	return "https://" + r.Host + path
+}
+
+// shareableContent returns the content of a snippet to show outside its page, in feeds and previews
+// content that has to be unlocked or revealed is left out
+func shareableContent(s *models.Snippet) string {
+	if s.Protected || s.ViewLimit {
+		return ""
+	}
+	return s.Content
+}
+
+// ownedSnippet helper is snippetFromRequest restricted to the snippet owner
+func (app *application) ownedSnippet(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	s, ok := app.snippetFromRequest(w, r)
+	if !ok {
+		return nil, false
+	}
+	if s.UserID != app.authenticatedUserID(r) {
+		app.clientError(w, http.StatusForbidden)
+		return nil, false
+	}
+	return s, true
+}
+
+// queryInt helper reads an integer query string parameter, returning def if it is absent
+func queryInt(r *http.Request, key string, def int) (int, error) {
+	value := r.URL.Query().Get(key)
+	if value == "" {
+		return def, nil
+	}
+	return strconv.Atoi(value)
+}
+
+// filenameRX matches runs of characters left out of download filenames
+var filenameRX = regexp.MustCompile(`[^a-z0-9]+`)
+
+// snippetFilename derives a download filename from the title and language of a snippet
+func snippetFilename(s *models.Snippet) string {
+	name := strings.Trim(filenameRX.ReplaceAllString(strings.ToLower(s.Title), "-"), "-")
+	if len(name) > 64 {
+		name = strings.TrimRight(name[:64], "-")
+	}
+	if name == "" {
+		name = fmt.Sprintf("snippet-%d", s.ID)
+	}
+
+	ext := ".txt"
+	if l, ok := highlight.Lookup(s.Language); ok {
+		ext = l.Extension
+	}
+	return name + ext
+}
+
+// visibility helper reads the visibility field of a validated form, defaulting to public
+func visibility(form *forms.Form) string {
+	if v := form.Get("visibility"); v != "" {
+		return v
+	}
+	return models.Public
+}
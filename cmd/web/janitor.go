@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// janitor permanently removes expired, used up and long deleted snippets every interval
+// along with the attachments they leave behind and old generated images
+// it runs a first sweep straight away and returns once ctx is cancelled
+func (app *application) janitor(ctx context.Context, interval time.Duration, batchSize int) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		app.sweep(ctx, batchSize)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sweep purges snippets, their attachments and then cached images in batches of batchSize until none are left
+// small batches keep each DELETE from locking the table for long
+func (app *application) sweep(ctx context.Context, batchSize int) {
+	purges := []struct {
+		name  string
+		purge func(int) (int, error)
+	}{
+		{"expired snippets", app.snippets.PurgeExpired},
+		{"deleted snippets", app.snippets.PurgeDeleted},
+		{"orphaned attachments", app.purgeAttachments},
+		{"cached images", app.purgeImages},
+	}
+
+	for _, p := range purges {
+		total := 0
+		for ctx.Err() == nil {
+			n, err := p.purge(batchSize)
+			if err != nil {
+				app.errorLog.Print(err)
+				break
+			}
+			total += n
+			if n < batchSize {
+				break
+			}
+		}
+		if total > 0 {
+			app.infoLog.Printf("Purged %d %s", total, p.name)
+		}
+	}
+}
+
+// purgeImages removes up to limit cards and code images drawn longer than cacheTTL ago
+// images still in use are drawn again on their next request
+func (app *application) purgeImages(limit int) (int, error) {
+	before := time.Now().Add(-app.cacheTTL)
+	total := 0
+	for _, prefix := range []string{"cards", "code"} {
+		n, err := app.cache.Purge(prefix, before, limit-total)
+		total += n
+		if err != nil || total >= limit {
+			return total, err
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"robert-tu.net/snippetbox/pkg/blob"
+	"robert-tu.net/snippetbox/pkg/models/mock"
+)
+
+// purgeModel counts purge calls, returning full batches until remaining runs out
+type purgeModel struct {
+	mock.SnippetModel
+	remaining int
+	calls     int
+}
+
+func (m *purgeModel) PurgeExpired(limit int) (int, error) {
+	m.calls++
+	n := limit
+	if m.remaining < n {
+		n = m.remaining
+	}
+	m.remaining -= n
+	return n, nil
+}
+
+// newTestCache returns an empty image cache in a temporary directory
+func newTestCache(t *testing.T) *blob.FileStore {
+	cache, err := blob.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cache
+}
+
+func TestSweep(t *testing.T) {
+	var infoLog bytes.Buffer
+	snippets := &purgeModel{remaining: 25}
+	app := &application{
+		errorLog:    log.New(io.Discard, "", 0),
+		infoLog:     log.New(&infoLog, "", 0),
+		snippets:    snippets,
+		attachments: &mock.AttachmentModel{},
+		cache:       newTestCache(t),
+	}
+
+	app.sweep(context.Background(), 10)
+
+	// two full batches and a partial one
+	if snippets.calls != 3 {
+		t.Errorf("want %d purge calls; got %d", 3, snippets.calls)
+	}
+	if !bytes.Contains(infoLog.Bytes(), []byte("Purged 25 expired snippets")) {
+		t.Errorf("want log to contain the purge count; got %q", infoLog.String())
+	}
+}
+
+func TestJanitorStops(t *testing.T) {
+	app := &application{
+		errorLog:    log.New(io.Discard, "", 0),
+		infoLog:     log.New(io.Discard, "", 0),
+		snippets:    &purgeModel{},
+		attachments: &mock.AttachmentModel{},
+		cache:       newTestCache(t),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		app.janitor(ctx, time.Hour, 10)
+		close(done)
+	}()
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("want janitor to stop when its context is cancelled")
+	}
+}
+
+func TestPurgeImages(t *testing.T) {
+	cache := newTestCache(t)
+	app := &application{cache: cache, cacheTTL: time.Hour}
+
+	old := time.Now().Add(-2 * time.Hour)
+	for _, key := range []string{"cards/a.png", "cards/b.png", "code/c.svg", "code/d.png", "attachments/e"} {
+		if err := cache.Put(key, strings.NewReader("x"), 1, ""); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(filepath.Join(cache.Dir, filepath.FromSlash(key)), old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := cache.Put("code/new.svg", strings.NewReader("x"), 1, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// batches span both prefixes
+	n, err := app.purgeImages(3)
+	if err != nil || n != 3 {
+		t.Fatalf("want %d images purged; got %d, %v", 3, n, err)
+	}
+	n, err = app.purgeImages(3)
+	if err != nil || n != 1 {
+		t.Fatalf("want %d image purged; got %d, %v", 1, n, err)
+	}
+
+	// recent images and other objects are kept
+	for _, key := range []string{"code/new.svg", "attachments/e"} {
+		rc, err := cache.Get(key)
+		if err != nil {
+			t.Errorf("want %s kept; got %v", key, err)
+			continue
+		}
+		rc.Close()
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"database/sql"
 	"flag"
@@ -8,8 +9,14 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 
+	"robert-tu.net/snippetbox/pkg/blob"
 	"robert-tu.net/snippetbox/pkg/models"
 	"robert-tu.net/snippetbox/pkg/models/mysql"
 
@@ -28,10 +35,32 @@ type application struct {
 	errorLog *log.Logger
 	// inline interface
 	snippets interface {
-		Insert(string, string, string) (int, error)
+		Insert(*models.Snippet, string) (int, error)
 		Get(int) (*models.Snippet, error)
+		Read(int) (*models.Snippet, error)
+		GetBySlug(string) (*models.Snippet, error)
 		Latest() ([]*models.Snippet, error)
+		Recent(string, int, int) ([]*models.Snippet, error)
+		Page(*models.Cursor, *models.Cursor, int) (*models.Page, error)
+		Search(string, int, int) ([]*models.Snippet, error)
+		ByTag(string, int, int) ([]*models.Snippet, error)
+		Trending(time.Time, int) ([]*models.Snippet, error)
+		ByUser(int) ([]*models.Snippet, error)
+		Forks(int) ([]*models.Snippet, error)
+		Update(int, int, int, string, string, string, string, []string) error
+		SetPassphrase(int, int, string) error
+		SetExpires(int, int, time.Time) error
+		Unlock(int, string) error
+		Revisions(int) ([]*models.Revision, error)
+		Revision(int, int) (*models.Revision, error)
+		Delete(int, int) error
+		Restore(int, int) error
+		Trash(int) ([]*models.Snippet, error)
+		PurgeDeleted(int) (int, error)
+		PurgeExpired(int) (int, error)
 	}
+	// choices of how long a snippet lives
+	expiryPresets []expiryPreset
 	templateCache map[string]*template.Template
 	session       *sessions.Session
 	// inline interface
@@ -40,6 +69,60 @@ type application struct {
 		Authenticate(string, string) (int, error)
 		Get(int) (*models.User, error)
 	}
+	// inline interface
+	tags interface {
+		ForSnippet(int) ([]string, error)
+		Cloud(int) ([]*models.Tag, error)
+	}
+	// inline interface
+	files interface {
+		ForSnippet(int) ([]*models.File, error)
+	}
+	// inline interface
+	attachments interface {
+		Insert(*models.Attachment) (int, error)
+		Get(int, int) (*models.Attachment, error)
+		ForSnippet(int) ([]*models.Attachment, error)
+		Delete(int) error
+		Orphans(int) ([]*models.Attachment, error)
+	}
+	// content of attachments
+	blobs blob.Store
+	// inline interface
+	comments interface {
+		Insert(int, int, int, string) (int, error)
+		Get(int) (*models.Comment, error)
+		ForSnippet(int) ([]*models.Comment, error)
+		Update(int, string) error
+		Delete(int) error
+	}
+	// inline interface
+	stars interface {
+		Star(int, int, string) error
+		Unstar(int, int) error
+		Starred(int, int) (bool, error)
+		ByUser(int) ([]*models.Star, error)
+	}
+	// inline interface
+	views interface {
+		Add(*models.ViewBatch) error
+		Daily(int, time.Time) ([]*models.DailyViews, error)
+		Totals(int) (int, int, error)
+		Referrers(int, int) ([]*models.Referrer, error)
+	}
+	// views waiting to be saved
+	viewCounter *viewCounter
+	// generated images, kept by content hash
+	cache interface {
+		blob.Store
+		Purge(string, time.Time, int) (int, error)
+	}
+	// how long generated images are kept before the janitor removes them
+	cacheTTL time.Duration
+	// slots for drawing images that aren't cached
+	renders chan struct{}
+	// address of the site in links shared outside it, empty to use the request host
+	baseURL string
 }
 
 func main() {
@@ -49,6 +132,23 @@ func main() {
 	ds := flag.String("dsn", "web:pass@/snippetbox?parseTime=true", "My SQL data source")
 	// define flag for session secret
 	secret := flag.String("secret", "s6Ndh+pPbnzHbS*+9Pk8qGWhTzbpa@ge", "Secret Key")
+	// define flag for the expiry choices offered when creating a snippet
+	presets := flag.String("expiry-presets", "1d,1w,365d", "Comma separated snippet expiry choices (e.g. 1h,1d,2w)")
+	// define flags for the janitor purging old snippets
+	janitorInterval := flag.Duration("janitor-interval", time.Hour, "Time between purges of expired snippets (0 disables)")
+	janitorBatch := flag.Int("janitor-batch", 500, "Maximum snippets deleted by one purge query")
+	// define flag for how often counted views are saved
+	baseURL := flag.String("base-url", "", "Public address of the site used in embeds (e.g. https://snippetbox.example.com), defaults to the request host")
+	viewsInterval := flag.Duration("views-interval", 30*time.Second, "Time between saves of counted snippet views")
+	// define flags for where attachments are stored, S3 is used when an endpoint is given
+	blobDir := flag.String("blob-dir", "./data/blobs", "Directory holding attachments")
+	s3Endpoint := flag.String("s3-endpoint", "", "S3 compatible endpoint for attachments (e.g. http://localhost:9000)")
+	s3Bucket := flag.String("s3-bucket", "snippetbox", "S3 bucket for attachments")
+	s3Region := flag.String("s3-region", "us-east-1", "S3 region")
+	s3AccessKey := flag.String("s3-access-key", "", "S3 access key")
+	s3SecretKey := flag.String("s3-secret-key", "", "S3 secret key")
+	cacheDir := flag.String("cache-dir", "./data/cache", "Directory holding generated images")
+	cacheTTL := flag.Duration("cache-ttl", 7*24*time.Hour, "Time generated images are kept in the cache")
 	flag.Parse()
 
 	// INFO logger
@@ -64,6 +164,32 @@ func main() {
 	// defer connection pool close before main
 	defer db.Close()
 
+	expiryPresets, err := parseExpiryPresets(*presets)
+	if err != nil {
+		errLog.Fatal(err)
+	}
+	// a batch of zero would purge nothing while the janitor looks busy
+	if *janitorBatch < 1 {
+		errLog.Fatalf("janitor batch %d must be positive", *janitorBatch)
+	}
+
+	// initialize attachment storage
+	var blobs blob.Store
+	if *s3Endpoint != "" {
+		blobs, err = blob.NewS3Store(*s3Endpoint, *s3Bucket, *s3Region, *s3AccessKey, *s3SecretKey)
+	} else {
+		blobs, err = blob.NewFileStore(*blobDir)
+	}
+	if err != nil {
+		errLog.Fatal(err)
+	}
+
+	// generated images stay on local disk whatever holds the attachments
+	cache, err := blob.NewFileStore(*cacheDir)
+	if err != nil {
+		errLog.Fatal(err)
+	}
+
 	// initialize template cache
 	templateCache, err := newTemplateCache("./ui/html/")
 	if err != nil {
@@ -84,11 +210,48 @@ func main() {
 		infoLog:       infoLog,
 		errorLog:      errLog,
 		snippets:      &mysql.SnippetModel{DB: db},
+		expiryPresets: expiryPresets,
 		templateCache: templateCache,
 		session:       session,
 		users:         &mysql.UserModel{DB: db},
+		tags:          &mysql.TagModel{DB: db},
+		files:         &mysql.FileModel{DB: db},
+		attachments:   &mysql.AttachmentModel{DB: db},
+		blobs:         blobs,
+		cache:         cache,
+		cacheTTL:      *cacheTTL,
+		renders:       make(chan struct{}, runtime.NumCPU()),
+		comments:      &mysql.CommentModel{DB: db},
+		stars:         &mysql.StarModel{DB: db},
+		views:         &mysql.ViewModel{DB: db},
+		viewCounter:   newViewCounter([]byte(*secret)),
+		baseURL:       strings.TrimRight(*baseURL, "/"),
 	}
 
+	// cancelled on SIGINT or SIGTERM to shut down cleanly
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// permanently remove expired snippets and those left in the trash
+	var wg sync.WaitGroup
+	if *janitorInterval > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			app.janitor(ctx, *janitorInterval, *janitorBatch)
+		}()
+	}
+
+	// save counted views in the background
+	// stopped only after the server has finished its last requests
+	flushCtx, stopFlush := context.WithCancel(context.Background())
+	var flushWG sync.WaitGroup
+	flushWG.Add(1)
+	go func() {
+		defer flushWG.Done()
+		app.flushViews(flushCtx, *viewsInterval)
+	}()
+
 	// initialize tls.Config struct
 	tlsConfig := &tls.Config{
 		CurvePreferences: []tls.CurveID{
@@ -111,21 +274,40 @@ func main() {
 
 	// initialize http.Server struct
 	srv := &http.Server{
-		Addr:         *addr,
-		ErrorLog:     errLog,
-		Handler:      app.routes(),
-		TLSConfig:    tlsConfig,
-		IdleTimeout:  time.Minute,     // default Go 3 min
-		ReadTimeout:  5 * time.Second, // short read timeout migitigates risk from slow-client attacks
-		WriteTimeout: 10 * time.Second,
+		Addr:              *addr,
+		ErrorLog:          errLog,
+		Handler:           app.routes(),
+		TLSConfig:         tlsConfig,
+		IdleTimeout:       time.Minute,     // default Go 3 min
+		ReadHeaderTimeout: 5 * time.Second, // short header timeout migitigates risk from slow-client attacks
+		ReadTimeout:       uploadTimeout,   // bodies are capped in size, attachments need the time to arrive
+		WriteTimeout:      uploadTimeout + 10*time.Second,
 	}
 
 	// start new web server calling server struct
 	// returns error in log
 	infoLog.Printf("Starting server on %s", *addr)
-	err = srv.ListenAndServeTLS("./tls/cert.pem", "./tls/key.pem")
-	errLog.Fatal(err)
+	errs := make(chan error, 1)
+	go func() {
+		errs <- srv.ListenAndServeTLS("./tls/cert.pem", "./tls/key.pem")
+	}()
 
+	select {
+	case err = <-errs:
+		errLog.Fatal(err)
+	case <-ctx.Done():
+	}
+
+	// let in-flight requests and the current janitor sweep finish
+	infoLog.Print("Shutting down server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err = srv.Shutdown(shutdownCtx); err != nil {
+		errLog.Print(err)
+	}
+	stopFlush()
+	flushWG.Wait()
+	wg.Wait()
 }
 
 // openDB() function wraps sql.Open()
@@ -22,6 +22,14 @@ func secureHeaders(next http.Handler) http.Handler {
 	})
 }
 
+// allowFraming lifts the X-Frame-Options header set by secureHeaders so other sites can embed a page
+func allowFraming(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Del("X-Frame-Options")
+		next.ServeHTTP(w, r)
+	})
+}
+
 // request logging function
 func (app *application) logRequests(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -63,6 +71,21 @@ func (app *application) requireAuthentication(next http.Handler) http.Handler {
 	})
 }
 
+// limitBody rejects request bodies larger than n bytes before anything parses them
+func (app *application) limitBody(n int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > n {
+				app.clientError(w, http.StatusRequestEntityTooLarge)
+				return
+			}
+			// bodies sent without a length fail while being read instead
+			r.Body = http.MaxBytesReader(w, r.Body, n)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 // NoSurf middleware function for token based CSRF mitigation
 func noSurf(next http.Handler) http.Handler {
 	csrfHandler := nosurf.New(next)
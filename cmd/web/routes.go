@@ -15,16 +15,82 @@ func (app *application) routes() http.Handler {
 	// create dynamic middleware chain
 	dynamicMiddleware := alice.New(app.session.Enable, noSurf, app.authenticate)
 
+	// embeds are framed by other sites, without a session so they show what anyone would see
+	embedMiddleware := alice.New(allowFraming)
+
 	// initialize new servemux via pat
 	mux := pat.New()
 	// register home as handler for "/"
 	mux.Get("/", dynamicMiddleware.ThenFunc(app.home))
+	mux.Get("/snippets", dynamicMiddleware.ThenFunc(app.listSnippets))
+	mux.Get("/search", dynamicMiddleware.ThenFunc(app.search))
+	mux.Get("/trending", dynamicMiddleware.ThenFunc(app.trending))
+	mux.Get("/tag/:name", dynamicMiddleware.ThenFunc(app.tagSnippets))
+	mux.Get("/oembed", http.HandlerFunc(app.oembed))
+	// feeds only list public snippets so they don't need a session
+	mux.Get("/feed.atom", http.HandlerFunc(app.atomFeed))
+	mux.Get("/feed.rss", http.HandlerFunc(app.rssFeed))
+	mux.Get("/tag/:name/feed.atom", http.HandlerFunc(app.atomFeed))
+	mux.Get("/tag/:name/feed.rss", http.HandlerFunc(app.rssFeed))
+	mux.Get("/user/:id/feed.atom", http.HandlerFunc(app.atomFeed))
+	mux.Get("/user/:id/feed.rss", http.HandlerFunc(app.rssFeed))
 	// register handlers
 	// pat matches patterns in order so wildcard route is placed lower
 	// requires authentication
 	mux.Post("/snippet/create", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.createSnippet))
 	mux.Get("/snippet/create", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.createSnippetForm))
 	mux.Get("/snippet/:id", dynamicMiddleware.ThenFunc(app.showSnippet))
+	mux.Get("/s/:slug", dynamicMiddleware.ThenFunc(app.showSnippet))
+	mux.Post("/s/:slug/unlock", dynamicMiddleware.ThenFunc(app.unlockSnippet))
+	mux.Post("/s/:slug/view", dynamicMiddleware.ThenFunc(app.revealSnippet))
+	mux.Get("/s/:slug/raw", dynamicMiddleware.ThenFunc(app.rawSnippet))
+	mux.Get("/s/:slug/download", dynamicMiddleware.ThenFunc(app.downloadSnippet))
+	mux.Get("/s/:slug/embed.js", http.HandlerFunc(app.embedScript))
+	mux.Get("/s/:slug/embed", embedMiddleware.ThenFunc(app.embedSnippet))
+	mux.Get("/s/:slug/card.png", dynamicMiddleware.ThenFunc(app.cardImage))
+	mux.Get("/s/:slug/image.png", dynamicMiddleware.Then(app.codeImage("png")))
+	mux.Get("/s/:slug/image.svg", dynamicMiddleware.Then(app.codeImage("svg")))
+	mux.Get("/s/:slug/zip", dynamicMiddleware.ThenFunc(app.zipSnippet))
+	mux.Get("/s/:slug/attachments/:aid", dynamicMiddleware.ThenFunc(app.showAttachment))
+	mux.Post("/s/:slug/comments", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.createComment))
+	mux.Post("/s/:slug/star", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.starSnippet))
+	mux.Post("/s/:slug/unstar", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.unstarSnippet))
+	mux.Get("/s/:slug/fork", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.forkSnippet))
+	mux.Get("/s/:slug/history", dynamicMiddleware.ThenFunc(app.snippetHistory))
+	mux.Get("/s/:slug/diff", dynamicMiddleware.ThenFunc(app.snippetDiff))
+	mux.Get("/snippet/:id/edit", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.editSnippetForm))
+	mux.Post("/snippet/:id/edit", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.editSnippet))
+	mux.Post("/snippet/:id/unlock", dynamicMiddleware.ThenFunc(app.unlockSnippet))
+	mux.Post("/snippet/:id/view", dynamicMiddleware.ThenFunc(app.revealSnippet))
+	mux.Get("/snippet/:id/raw", dynamicMiddleware.ThenFunc(app.rawSnippet))
+	mux.Get("/snippet/:id/download", dynamicMiddleware.ThenFunc(app.downloadSnippet))
+	mux.Get("/snippet/:id/embed.js", http.HandlerFunc(app.embedScript))
+	mux.Get("/snippet/:id/embed", embedMiddleware.ThenFunc(app.embedSnippet))
+	mux.Get("/snippet/:id/card.png", dynamicMiddleware.ThenFunc(app.cardImage))
+	mux.Get("/snippet/:id/image.png", dynamicMiddleware.Then(app.codeImage("png")))
+	mux.Get("/snippet/:id/image.svg", dynamicMiddleware.Then(app.codeImage("svg")))
+	mux.Get("/snippet/:id/zip", dynamicMiddleware.ThenFunc(app.zipSnippet))
+	mux.Get("/snippet/:id/attachments", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.attachmentsPage))
+	// uploads are size limited before the CSRF check parses the form
+	mux.Post("/snippet/:id/attachments", alice.New(app.limitBody(maxUploadSize)).Extend(dynamicMiddleware).Append(app.requireAuthentication).ThenFunc(app.uploadAttachments))
+	mux.Get("/snippet/:id/attachments/:aid", dynamicMiddleware.ThenFunc(app.showAttachment))
+	mux.Post("/snippet/:id/attachments/:aid/delete", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.deleteAttachment))
+	mux.Get("/snippet/:id/stats", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.snippetStats))
+	mux.Post("/snippet/:id/comments", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.createComment))
+	mux.Post("/snippet/:id/star", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.starSnippet))
+	mux.Post("/snippet/:id/unstar", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.unstarSnippet))
+	mux.Get("/comment/:id/edit", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.editCommentForm))
+	mux.Post("/comment/:id/edit", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.editComment))
+	mux.Post("/comment/:id/delete", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.deleteComment))
+	mux.Get("/snippet/:id/fork", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.forkSnippet))
+	mux.Get("/snippet/:id/expiry", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.expiryForm))
+	mux.Post("/snippet/:id/expiry", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.updateExpiry))
+	mux.Get("/snippet/:id/history", dynamicMiddleware.ThenFunc(app.snippetHistory))
+	mux.Post("/snippet/:id/delete", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.deleteSnippet))
+	mux.Post("/snippet/:id/restore", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.restoreSnippet))
+	mux.Get("/snippet/:id/diff", dynamicMiddleware.ThenFunc(app.snippetDiff))
+	mux.Get("/compare/s/:a/:b", dynamicMiddleware.Then(app.compareSnippets(true)))
+	mux.Get("/compare/:a/:b", dynamicMiddleware.Then(app.compareSnippets(false)))
 
 	// user authentication
 	mux.Get("/user/signup", dynamicMiddleware.ThenFunc(app.signupUserForm))
@@ -32,6 +98,10 @@ func (app *application) routes() http.Handler {
 	mux.Get("/user/login", dynamicMiddleware.ThenFunc(app.loginUserForm))
 	mux.Post("/user/login", dynamicMiddleware.ThenFunc(app.loginUser))
 	mux.Post("/user/logout", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.logoutUser))
+	mux.Get("/user/snippets", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.userSnippets))
+	mux.Get("/user/trash", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.trash))
+	mux.Get("/user/starred", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.starredSnippets))
+	mux.Post("/user/starred/:id/remove", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.removeStar))
 
 	// test routes
 	mux.Get("/ping", http.HandlerFunc(ping))
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// stars given within this window count towards trending
+const trendingWindow = 7 * 24 * time.Hour
+
+// snippets shown on the trending page
+const trendingSize = 20
+
+// starSnippet handler adds a snippet to the starred snippets of the user
+func (app *application) starSnippet(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.snippetFromRequest(w, r)
+	if !ok || !app.requireReadable(w, r, s) {
+		return
+	}
+
+	err := app.stars.Star(app.authenticatedUserID(r), s.ID, s.Title)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, snippetURL(s), http.StatusSeeOther)
+}
+
+// unstarSnippet handler removes a snippet from the starred snippets of the user
+func (app *application) unstarSnippet(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.snippetFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.stars.Unstar(app.authenticatedUserID(r), s.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, snippetURL(s), http.StatusSeeOther)
+}
+
+// removeStar handler unstars a snippet from the starred page, which works after it has gone
+func (app *application) removeStar(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Query().Get(":id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	err = app.stars.Unstar(app.authenticatedUserID(r), id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.session.Put(r, "flash", "Star removed.")
+
+	http.Redirect(w, r, "/user/starred", http.StatusSeeOther)
+}
+
+// starredSnippets handler lists the snippets starred by the logged in user
+func (app *application) starredSnippets(w http.ResponseWriter, r *http.Request) {
+	stars, err := app.stars.ByUser(app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.render(w, r, "starred.page.tmpl", &templateData{
+		Stars: stars,
+	})
+}
+
+// trending handler lists the public snippets starred most in the last week
+func (app *application) trending(w http.ResponseWriter, r *http.Request) {
+	s, err := app.snippets.Trending(time.Now().Add(-trendingWindow), trendingSize)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.render(w, r, "trending.page.tmpl", &templateData{
+		Snippets: s,
+	})
+}
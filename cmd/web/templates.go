@@ -1,23 +1,156 @@
 package main
 
 import (
+	"fmt"
 	"html/template"
 	"path/filepath"
+	"regexp"
+	"strings"
 	"time"
+	"unicode/utf8"
 
+	"robert-tu.net/snippetbox/pkg/diff"
 	"robert-tu.net/snippetbox/pkg/forms"
+	"robert-tu.net/snippetbox/pkg/highlight"
 	"robert-tu.net/snippetbox/pkg/models"
 )
 
 // define templateData type struct
 type templateData struct {
-	Snippet         *models.Snippet
-	Snippets        []*models.Snippet
-	CurrentYear     int
-	Form            *forms.Form
-	Flash           string
-	IsAuthenticated bool
-	CSRFToken       string
+	Snippet             *models.Snippet
+	Permalink           string
+	Parent              *models.Snippet
+	Snippets            []*models.Snippet
+	Revisions           []*models.Revision
+	Diff                *diffData
+	Pagination          *pagination
+	Query               string
+	Tag                 string
+	TagCloud            []cloudTag
+	Lines               []codeLine
+	Rendered            template.HTML
+	Files               []fileView
+	Attachments         []*models.Attachment
+	Comments            []commentView
+	Comment             *models.Comment
+	ReplyTo             *models.Comment
+	Starred             bool
+	Stars               []*models.Star
+	Stats               *statsData
+	Meta                *pageMeta
+	EmbedURL            string
+	OEmbedURL           string
+	FileFields          []fileField
+	Languages           []highlight.Language
+	ExpiryPresets       []expiryPreset
+	CurrentYear         int
+	Form                *forms.Form
+	Flash               string
+	IsAuthenticated     bool
+	AuthenticatedUserID int
+	CSRFToken           string
+}
+
+// default and maximum number of snippets in a listing page
+const (
+	defaultPageSize = 10
+	maxPageSize     = 100
+)
+
+// shortest passphrase accepted for a protected snippet
+const minPassphraseLength = 8
+
+// most views a view limited snippet can allow
+const maxViewLimit = 1000
+
+// limits on the tags of a snippet
+const (
+	maxTags      = 10
+	maxTagLength = 32
+	// tags shown in the tag cloud
+	tagCloudSize = 30
+)
+
+// cloudTag is a tag with a weight from 1 to 5 based on how often it is used
+type cloudTag struct {
+	Name   string
+	Count  int
+	Weight int
+}
+
+// newTagCloud scales tag counts to weights relative to the most used tag
+func newTagCloud(tags []*models.Tag) []cloudTag {
+	max := 1
+	for _, t := range tags {
+		if t.Count > max {
+			max = t.Count
+		}
+	}
+	cloud := make([]cloudTag, len(tags))
+	for i, t := range tags {
+		cloud[i] = cloudTag{Name: t.Name, Count: t.Count, Weight: 1 + 4*t.Count/max}
+	}
+	return cloud
+}
+
+// codeLine is a highlighted line of a snippet linking to a line selection
+type codeLine struct {
+	highlight.Line
+	Selected bool
+	Href     string
+}
+
+// newCodeLines marks the lines between first and last as selected
+// clicking a line selects it, or extends a single selected line into a range
+func newCodeLines(lines []highlight.Line, first, last int) []codeLine {
+	code := make([]codeLine, len(lines))
+	for i, l := range lines {
+		href := fmt.Sprintf("?lines=%d#L%d", l.Number, l.Number)
+		if first > 0 && first == last && l.Number != first {
+			from, to := first, l.Number
+			if to < from {
+				from, to = to, from
+			}
+			href = fmt.Sprintf("?lines=%d-%d#L%d", from, to, from)
+		}
+		code[i] = codeLine{
+			Line:     l,
+			Selected: l.Number >= first && l.Number <= last,
+			Href:     href,
+		}
+	}
+	return code
+}
+
+// parseLineRange reads a selection like "10" or "10-20", returning zeros if it is invalid
+func parseLineRange(s string) (int, int) {
+	var first, last int
+	if n, _ := fmt.Sscanf(s, "%d-%d", &first, &last); n == 1 {
+		last = first
+	}
+	if first < 1 || last < first {
+		return 0, 0
+	}
+	return first, last
+}
+
+// pagination holds links to the neighbouring pages of a listing, empty if there is none
+type pagination struct {
+	PrevURL string
+	NextURL string
+}
+
+// diffData holds a rendered comparison between two texts
+type diffData struct {
+	From     string
+	To       string
+	Hunks    []diff.Hunk
+	Rows     []diff.Row
+	Split    bool
+	Changed  bool
+	TooLarge bool
+	BaseURL  string
+	PatchURL string
 }
 
 // humanDate function returning formatted date
@@ -28,9 +161,108 @@ func humanDate(t time.Time) string {
 	return t.UTC().Format("Jan 02 2006 at 15:04")
 }
 
+// humanExpiry function formats an expiry date, which is zero for snippets that never expire
+func humanExpiry(t time.Time) string {
+	if t.IsZero() {
+		return "Never"
+	}
+	return humanDate(t)
+}
+
+// humanSize function formats a number of bytes
+func humanSize(n int64) string {
+	switch {
+	case n < 1<<10:
+		return fmt.Sprintf("%d B", n)
+	case n < 1<<20:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	}
+}
+
+// number of characters of context shown around a search match
+const excerptContext = 60
+
+// termsRX returns a case-insensitive pattern matching any word of a search query
+// returns nil if the query has no words
+func termsRX(query string) *regexp.Regexp {
+	var terms []string
+	for _, term := range strings.Fields(query) {
+		// drop full-text search operators
+		term = strings.Trim(term, `"+-~<>()*`)
+		if term != "" {
+			terms = append(terms, regexp.QuoteMeta(term))
+		}
+	}
+	if len(terms) == 0 {
+		return nil
+	}
+	return regexp.MustCompile(`(?i)` + strings.Join(terms, "|"))
+}
+
+// mark function escapes text and wraps words of the query in <mark> tags
+func mark(text, query string) template.HTML {
+	rx := termsRX(query)
+	if rx == nil {
+		return template.HTML(template.HTMLEscapeString(text))
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, m := range rx.FindAllStringIndex(text, -1) {
+		b.WriteString(template.HTMLEscapeString(text[last:m[0]]))
+		b.WriteString("<mark>")
+		b.WriteString(template.HTMLEscapeString(text[m[0]:m[1]]))
+		b.WriteString("</mark>")
+		last = m[1]
+	}
+	b.WriteString(template.HTMLEscapeString(text[last:]))
+	return template.HTML(b.String())
+}
+
+// excerpt function returns a highlighted fragment of text around the first match of query
+func excerpt(text, query string) template.HTML {
+	start := 0
+	if rx := termsRX(query); rx != nil {
+		if m := rx.FindStringIndex(text); m != nil {
+			start = m[0] - excerptContext
+		}
+	}
+	if start < 0 {
+		start = 0
+	}
+	end := start + 3*excerptContext
+	if end > len(text) {
+		end = len(text)
+	}
+	// move to rune boundaries
+	for start > 0 && !utf8.RuneStart(text[start]) {
+		start--
+	}
+	for end < len(text) && !utf8.RuneStart(text[end]) {
+		end++
+	}
+
+	fragment := mark(text[start:end], query)
+	if start > 0 {
+		fragment = "&hellip;" + fragment
+	}
+	if end < len(text) {
+		fragment += "&hellip;"
+	}
+	return fragment
+}
+
 // initialize template.FuncMap as global variable
 var functions = template.FuncMap{
-	"humanDate": humanDate,
+	"humanDate":   humanDate,
+	"humanExpiry": humanExpiry,
+	"humanSize":   humanSize,
+	"snippetURL":  snippetURL,
+	"mark":        mark,
+	"excerpt":     excerpt,
+	"shareable":   shareableContent,
 }
 
 // define newTemplateCache function
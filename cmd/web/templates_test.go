@@ -1,6 +1,8 @@
 package main
 
 import (
+	"html/template"
+	"strings"
 	"testing"
 	"time"
 )
@@ -41,3 +43,83 @@ func TestHumanDate(t *testing.T) {
 		})
 	}
 }
+
+func TestHumanExpiry(t *testing.T) {
+	if got := humanExpiry(time.Time{}); got != "Never" {
+		t.Errorf("want %q; got %q", "Never", got)
+	}
+
+	tm := time.Date(2021, 12, 17, 10, 0, 0, 0, time.UTC)
+	if got := humanExpiry(tm); got != "Dec 17 2021 at 10:00" {
+		t.Errorf("want %q; got %q", "Dec 17 2021 at 10:00", got)
+	}
+}
+
+func TestMark(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		query string
+		want  template.HTML
+	}{
+		{"Single term", "An old silent pond", "pond", "An old silent <mark>pond</mark>"},
+		{"Case insensitive", "An old silent pond", "OLD", "An <mark>old</mark> silent pond"},
+		{"Multiple terms", "An old silent pond", "old pond", "An <mark>old</mark> silent <mark>pond</mark>"},
+		{"Escapes HTML", "<b>pond</b>", "pond", "&lt;b&gt;<mark>pond</mark>&lt;/b&gt;"},
+		{"Operators only", "An old silent pond", "+ -", "An old silent pond"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mark(tt.text, tt.query)
+
+			if got != tt.want {
+				t.Errorf("want %q; got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	text := strings.Repeat("a ", 100) + "pond" + strings.Repeat(" b", 100)
+
+	got := string(excerpt(text, "pond"))
+
+	if !strings.HasPrefix(got, "&hellip;") || !strings.HasSuffix(got, "&hellip;") {
+		t.Errorf("want excerpt %q to be trimmed on both sides", got)
+	}
+	if !strings.Contains(got, "<mark>pond</mark>") {
+		t.Errorf("want excerpt %q to contain the match", got)
+	}
+
+	// short text is returned whole
+	if got := excerpt("An old silent pond", "pond"); got != "An old silent <mark>pond</mark>" {
+		t.Errorf("want whole text; got %q", got)
+	}
+}
+
+func TestParseLineRange(t *testing.T) {
+	tests := []struct {
+		name      string
+		s         string
+		wantFirst int
+		wantLast  int
+	}{
+		{"Single line", "10", 10, 10},
+		{"Range", "10-20", 10, 20},
+		{"Reversed range", "20-10", 0, 0},
+		{"Zero", "0", 0, 0},
+		{"Empty", "", 0, 0},
+		{"Invalid", "blah", 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first, last := parseLineRange(tt.s)
+
+			if first != tt.wantFirst || last != tt.wantLast {
+				t.Errorf("want %d-%d; got %d-%d", tt.wantFirst, tt.wantLast, first, last)
+			}
+		})
+	}
+}
@@ -1,18 +1,22 @@
 package main
 
 import (
+	"bytes"
 	"html"
 	"io"
 	"log"
+	"mime/multipart"
 	"net/http"
 	"net/http/cookiejar"
 	"net/http/httptest"
 	"net/url"
 	"regexp"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/golangcollege/sessions"
+	"robert-tu.net/snippetbox/pkg/blob"
 	"robert-tu.net/snippetbox/pkg/models/mock"
 )
 
@@ -44,14 +48,45 @@ func newTestApplication(t *testing.T) *application {
 	session.Lifetime = 12 * time.Hour
 	session.Secure = true
 
+	// blob store holding the content of the mock attachments
+	blobs, err := blob.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, content := range map[string]string{
+		"attachments/mock-frog": "\x89PNG\r\n\x1a\n",
+		"attachments/mock-page": "<script>alert(1)</script>",
+	} {
+		if err = blobs.Put(key, strings.NewReader(content), int64(len(content)), ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cache, err := blob.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
 	// initialize dependencies using mock
 	return &application{
 		errorLog:      log.New(io.Discard, "", 0),
 		infoLog:       log.New(io.Discard, "", 0),
 		session:       session,
 		snippets:      &mock.SnippetModel{},
+		expiryPresets: []expiryPreset{{"1d", "1 day", 24 * time.Hour}, {"1w", "1 week", 7 * 24 * time.Hour}},
 		templateCache: templateCache,
 		users:         &mock.UserModel{},
+		tags:          &mock.TagModel{},
+		files:         &mock.FileModel{},
+		attachments:   &mock.AttachmentModel{},
+		blobs:         blobs,
+		cache:         cache,
+		cacheTTL:      time.Hour,
+		renders:       make(chan struct{}, 2),
+		comments:      &mock.CommentModel{},
+		stars:         &mock.StarModel{},
+		views:         &mock.ViewModel{},
+		viewCounter:   newViewCounter([]byte("salt")),
 	}
 }
 
@@ -112,3 +147,53 @@ func (ts *testServer) postForm(t *testing.T, urlPath string, form url.Values) (i
 	// return response status, headers, and body
 	return rs.StatusCode, rs.Header, body
 }
+
+// postMultipart sends a multipart form with the given fields and files
+func (ts *testServer) postMultipart(t *testing.T, urlPath string, fields url.Values, files map[string]string) (int, http.Header, []byte) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for name, values := range fields {
+		for _, value := range values {
+			mw.WriteField(name, value)
+		}
+	}
+	for name, content := range files {
+		fw, err := mw.CreateFormFile("attachments", name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write([]byte(content))
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	rs, err := ts.Client().Post(ts.URL+urlPath, mw.FormDataContentType(), &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer rs.Body.Close()
+	body, err := io.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rs.StatusCode, rs.Header, body
+}
+
+// login as the mock user and return the CSRF token of the session
+func (ts *testServer) login(t *testing.T) string {
+	_, _, body := ts.get(t, "/user/login")
+	csrfToken := extractCSRFToken(t, body)
+
+	form := url.Values{}
+	form.Add("email", "alice@gmail.com")
+	form.Add("password", "password123")
+	form.Add("csrf_token", csrfToken)
+
+	code, _, _ := ts.postForm(t, "/user/login", form)
+	if code != http.StatusSeeOther {
+		t.Fatalf("login: want %d; got %d", http.StatusSeeOther, code)
+	}
+	return csrfToken
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"robert-tu.net/snippetbox/pkg/models"
+)
+
+// buffered entries that trigger a flush before the next tick
+const viewFlushThreshold = 10000
+
+// days of views shown on the stats page
+const statsPeriod = 30
+
+// referrers shown on the stats page
+const statsReferrers = 10
+
+type dayKey struct {
+	snippetID int
+	day       string
+}
+
+type visitorKey struct {
+	dayKey
+	hash string
+}
+
+type referrerKey struct {
+	snippetID int
+	url       string
+}
+
+// viewCounter counts snippet views in memory so showing a snippet doesn't wait on a write
+// app.flushViews saves the counts in batches
+type viewCounter struct {
+	// mixed into visitor hashes so they can't be matched to an address
+	salt []byte
+
+	mu        sync.Mutex
+	views     map[dayKey]int
+	visitors  map[visitorKey]bool
+	referrers map[referrerKey]int
+	// signalled when the buffer passes viewFlushThreshold
+	full chan struct{}
+}
+
+// newViewCounter returns an empty counter
+func newViewCounter(salt []byte) *viewCounter {
+	c := &viewCounter{salt: salt, full: make(chan struct{}, 1)}
+	c.reset()
+	return c
+}
+
+func (c *viewCounter) reset() {
+	c.views = map[dayKey]int{}
+	c.visitors = map[visitorKey]bool{}
+	c.referrers = map[referrerKey]int{}
+}
+
+// record counts a view of snippet id by the client of r
+func (c *viewCounter) record(r *http.Request, id int) {
+	now := time.Now().UTC()
+	day := dayKey{snippetID: id, day: now.Format("2006-01-02")}
+	visitor := visitorKey{dayKey: day, hash: c.visitorHash(r, day.day)}
+	referrer := stripReferrer(r.Referer())
+
+	c.mu.Lock()
+	c.views[day]++
+	c.visitors[visitor] = true
+	if referrer != "" {
+		c.referrers[referrerKey{snippetID: id, url: referrer}]++
+	}
+	full := len(c.views)+len(c.visitors)+len(c.referrers) >= viewFlushThreshold
+	c.mu.Unlock()
+
+	if full {
+		select {
+		case c.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// visitorHash identifies a client for one day without storing its address
+func (c *viewCounter) visitorHash(r *http.Request, day string) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	h := sha256.New()
+	h.Write(c.salt)
+	h.Write([]byte(day + "\x00" + ip + "\x00" + r.UserAgent()))
+	return hex.EncodeToString(h.Sum(nil))[:32]
+}
+
+// take empties the counter, returning what it held
+func (c *viewCounter) take() *models.ViewBatch {
+	c.mu.Lock()
+	views, visitors, referrers := c.views, c.visitors, c.referrers
+	c.reset()
+	c.mu.Unlock()
+
+	batch := &models.ViewBatch{}
+	for k, n := range views {
+		batch.Views = append(batch.Views, &models.DailyViews{SnippetID: k.snippetID, Day: parseDay(k.day), Views: n})
+	}
+	for k := range visitors {
+		batch.Visitors = append(batch.Visitors, &models.Visitor{SnippetID: k.snippetID, Day: parseDay(k.day), Hash: k.hash})
+	}
+	for k, n := range referrers {
+		batch.Referrers = append(batch.Referrers, &models.Referrer{SnippetID: k.snippetID, URL: k.url, Views: n})
+	}
+	return batch
+}
+
+func parseDay(day string) time.Time {
+	t, _ := time.Parse("2006-01-02", day)
+	return t
+}
+
+// stripReferrer keeps the scheme, host and path of a web page address
+// query strings are dropped as they often carry tokens and make every link unique
+func stripReferrer(referer string) string {
+	u, err := url.Parse(referer)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ""
+	}
+	s := u.Scheme + "://" + strings.ToLower(u.Host) + u.EscapedPath()
+	if len(s) > 255 {
+		// cut at a rune boundary, hosts may hold unicode
+		n := 255
+		for n > 0 && !utf8.RuneStart(s[n]) {
+			n--
+		}
+		s = s[:n]
+	}
+	return s
+}
+
+// flushViews saves the counted views every interval, or sooner when the buffer fills up
+// it saves whatever is left and returns once ctx is cancelled
+func (app *application) flushViews(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			app.saveViews()
+			return
+		case <-ticker.C:
+		case <-app.viewCounter.full:
+		}
+		app.saveViews()
+	}
+}
+
+// saveViews writes one batch of views, dropping it if that fails
+func (app *application) saveViews() {
+	batch := app.viewCounter.take()
+	if len(batch.Views) == 0 {
+		return
+	}
+	if err := app.views.Add(batch); err != nil {
+		app.errorLog.Printf("dropped views of %d snippet days: %v", len(batch.Views), err)
+	}
+}
+
+// define statsDay type for one row of the stats page
+type statsDay struct {
+	Day    time.Time
+	Views  int
+	Unique int
+	// bar length relative to the busiest day
+	Percent int
+}
+
+// define statsData type for the stats page
+type statsData struct {
+	Views     int
+	Unique    int
+	Days      []statsDay
+	Referrers []*models.Referrer
+}
+
+// snippetStats handler shows the owner of a snippet how often it was viewed
+func (app *application) snippetStats(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	stats := &statsData{}
+	var err error
+	stats.Views, stats.Unique, err = app.views.Totals(s.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	first := today.AddDate(0, 0, 1-statsPeriod)
+	daily, err := app.views.Daily(s.ID, first)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	stats.Days = statsDays(daily, first, today)
+
+	stats.Referrers, err = app.views.Referrers(s.ID, statsReferrers)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.render(w, r, "stats.page.tmpl", &templateData{
+		Snippet: s,
+		Stats:   stats,
+	})
+}
+
+// statsDays lists every day from first to last, newest first, filling in days without views
+func statsDays(daily []*models.DailyViews, first, last time.Time) []statsDay {
+	byDay := map[string]*models.DailyViews{}
+	max := 0
+	for _, d := range daily {
+		byDay[d.Day.Format("2006-01-02")] = d
+		if d.Views > max {
+			max = d.Views
+		}
+	}
+
+	var days []statsDay
+	for day := last; !day.Before(first); day = day.AddDate(0, 0, -1) {
+		row := statsDay{Day: day}
+		if d, ok := byDay[day.Format("2006-01-02")]; ok {
+			row.Views, row.Unique = d.Views, d.Unique
+			if max > 0 {
+				row.Percent = d.Views * 100 / max
+			}
+		}
+		days = append(days, row)
+	}
+	return days
+}
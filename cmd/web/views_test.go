@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"robert-tu.net/snippetbox/pkg/models"
+)
+
+func TestViewCounter(t *testing.T) {
+	c := newViewCounter([]byte("salt"))
+
+	view := func(addr, agent, referer string, id int) {
+		r := httptest.NewRequest("GET", "/snippet/1", nil)
+		r.RemoteAddr = addr
+		r.Header.Set("User-Agent", agent)
+		r.Header.Set("Referer", referer)
+		c.record(r, id)
+	}
+	view("192.0.2.1:1234", "firefox", "https://News.example.com/item?id=42&token=secret", 1)
+	view("192.0.2.1:5678", "firefox", "https://news.example.com/item?id=43", 1)
+	view("192.0.2.2:1234", "firefox", "", 1)
+	view("192.0.2.1:1234", "curl", "javascript:alert(1)", 1)
+	view("192.0.2.1:1234", "firefox", "", 4)
+
+	batch := c.take()
+
+	views := map[int]int{}
+	for _, v := range batch.Views {
+		views[v.SnippetID] += v.Views
+	}
+	if views[1] != 4 || views[4] != 1 {
+		t.Errorf("want 4 views of snippet 1 and 1 of snippet 4; got %v", views)
+	}
+
+	unique := map[int]int{}
+	for _, v := range batch.Visitors {
+		unique[v.SnippetID]++
+	}
+	if unique[1] != 3 || unique[4] != 1 {
+		t.Errorf("want 3 visitors of snippet 1 and 1 of snippet 4; got %v", unique)
+	}
+
+	if len(batch.Referrers) != 1 {
+		t.Fatalf("want 1 referrer; got %d", len(batch.Referrers))
+	}
+	if r := batch.Referrers[0]; r.URL != "https://news.example.com/item" || r.Views != 2 {
+		t.Errorf("want 2 views from https://news.example.com/item; got %d from %q", r.Views, r.URL)
+	}
+
+	if batch = c.take(); len(batch.Views) != 0 {
+		t.Errorf("want counter to be empty after take; got %d", len(batch.Views))
+	}
+}
+
+func TestStripReferrer(t *testing.T) {
+	tests := []struct {
+		referer string
+		want    string
+	}{
+		{"https://example.com/a/b?q=1#frag", "https://example.com/a/b"},
+		{"http://EXAMPLE.com", "http://example.com"},
+		{"android-app://com.example", ""},
+		{"not a url", ""},
+		{"", ""},
+		{"https://example.com/" + strings.Repeat("a", 300), "https://example.com/" + strings.Repeat("a", 235)},
+		{"https://" + strings.Repeat("é", 130), "https://" + strings.Repeat("é", 123)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.referer, func(t *testing.T) {
+			if got := stripReferrer(tt.referer); got != tt.want {
+				t.Errorf("want %q; got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+// viewStore collects the batches it is given
+type viewStore struct {
+	mu      sync.Mutex
+	batches []*models.ViewBatch
+}
+
+func (s *viewStore) Add(batch *models.ViewBatch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches = append(s.batches, batch)
+	return nil
+}
+
+func (s *viewStore) Daily(int, time.Time) ([]*models.DailyViews, error) { return nil, nil }
+func (s *viewStore) Totals(int) (int, int, error)                       { return 0, 0, nil }
+func (s *viewStore) Referrers(int, int) ([]*models.Referrer, error)     { return nil, nil }
+
+func TestFlushViews(t *testing.T) {
+	store := &viewStore{}
+	app := &application{
+		errorLog:    log.New(io.Discard, "", 0),
+		views:       store,
+		viewCounter: newViewCounter(nil),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		app.flushViews(ctx, time.Hour)
+		close(done)
+	}()
+
+	// views counted before stopping are saved on the way out
+	app.viewCounter.record(httptest.NewRequest("GET", "/", nil), 1)
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("want flushViews to stop when its context is cancelled")
+	}
+
+	if len(store.batches) != 1 || len(store.batches[0].Views) != 1 {
+		t.Errorf("want the remaining views to be saved in one batch; got %d batches", len(store.batches))
+	}
+}
+
+func TestStatsDays(t *testing.T) {
+	last := time.Date(2021, 12, 17, 0, 0, 0, 0, time.UTC)
+	first := last.AddDate(0, 0, -2)
+	daily := []*models.DailyViews{
+		{Day: first, Views: 10, Unique: 4},
+		{Day: last, Views: 5, Unique: 5},
+	}
+
+	days := statsDays(daily, first, last)
+
+	if len(days) != 3 {
+		t.Fatalf("want 3 days; got %d", len(days))
+	}
+	want := []statsDay{
+		{Day: last, Views: 5, Unique: 5, Percent: 50},
+		{Day: last.AddDate(0, 0, -1)},
+		{Day: first, Views: 10, Unique: 4, Percent: 100},
+	}
+	for i := range want {
+		if days[i] != want[i] {
+			t.Errorf("want day %d to be %+v; got %+v", i, want[i], days[i])
+		}
+	}
+}
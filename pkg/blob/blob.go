@@ -0,0 +1,34 @@
+// Package blob stores binary objects such as snippet attachments by key.
+package blob
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// ErrNotFound is returned when no object is stored under a key
+var ErrNotFound = errors.New("blob: not found")
+
+// Store saves, loads and removes objects
+type Store interface {
+	// Put stores size bytes read from r under key, replacing any existing object
+	Put(key string, r io.Reader, size int64, contentType string) error
+	// Get opens the object stored under key, the caller must close it
+	Get(key string) (io.ReadCloser, error)
+	// Delete removes the object stored under key, missing objects are not an error
+	Delete(key string) error
+}
+
+// keyRX matches keys made of slash separated names
+var keyRX = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9._-]*(/[A-Za-z0-9_-][A-Za-z0-9._-]*)*$`)
+
+// validKey rejects keys that could escape a directory or need escaping in a URL
+func validKey(key string) error {
+	if len(key) > 512 || !keyRX.MatchString(key) || strings.Contains(key, "..") {
+		return fmt.Errorf("blob: invalid key %q", key)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package blob
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testStore runs the behaviour shared by every Store
+func testStore(t *testing.T, s Store) {
+	t.Helper()
+
+	const key = "attachments/0f3a9c"
+	if _, err := s.Get(key); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("want ErrNotFound before Put; got %v", err)
+	}
+
+	content := "a small log file\n"
+	if err := s.Put(key, strings.NewReader(content), int64(len(content)), "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+
+	rc, err := s.Get(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != content {
+		t.Errorf("want %q; got %q", content, got)
+	}
+
+	if err = s.Delete(key); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.Get(key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("want ErrNotFound after Delete; got %v", err)
+	}
+	if err = s.Delete(key); err != nil {
+		t.Errorf("want deleting a missing object to succeed; got %v", err)
+	}
+
+	if err = s.Put("short", strings.NewReader("abc"), 4, ""); err == nil {
+		t.Error("want error when the body is shorter than its size")
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	s, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStore(t, s)
+}
+
+func TestFileStorePurge(t *testing.T) {
+	s, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	put := func(key string, age time.Duration) {
+		t.Helper()
+		if err := s.Put(key, strings.NewReader("x"), 1, ""); err != nil {
+			t.Fatal(err)
+		}
+		old := time.Now().Add(-age)
+		if err := os.Chtimes(filepath.Join(s.Dir, filepath.FromSlash(key)), old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+	put("cards/old1.png", 48*time.Hour)
+	put("cards/old2.png", 48*time.Hour)
+	put("cards/new.png", 0)
+	put("code/old.svg", 48*time.Hour)
+
+	before := time.Now().Add(-24 * time.Hour)
+
+	// stops at the limit
+	n, err := s.Purge("cards", before, 1)
+	if err != nil || n != 1 {
+		t.Fatalf("want 1 object purged; got %d, %v", n, err)
+	}
+	n, err = s.Purge("cards", before, 10)
+	if err != nil || n != 1 {
+		t.Fatalf("want 1 object purged; got %d, %v", n, err)
+	}
+
+	// recent objects and other prefixes are kept
+	for _, key := range []string{"cards/new.png", "code/old.svg"} {
+		rc, err := s.Get(key)
+		if err != nil {
+			t.Errorf("want %s kept; got %v", key, err)
+			continue
+		}
+		rc.Close()
+	}
+
+	// a prefix nothing was written under is empty
+	if n, err = s.Purge("missing", before, 10); err != nil || n != 0 {
+		t.Errorf("want nothing purged; got %d, %v", n, err)
+	}
+}
+
+func TestValidKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{"attachments/0f3a9c", true},
+		{"report.tar.gz", true},
+		{"", false},
+		{"/etc/passwd", false},
+		{"attachments/../../etc/passwd", false},
+		{"attachments//x", false},
+		{"a b", false},
+		{".hidden", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if got := validKey(tt.key) == nil; got != tt.want {
+				t.Errorf("want %t; got %t", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+// Package card draws the preview images shown when a snippet link is shared
+// text is set in the Go fonts, which are embedded so no system fonts are needed
+package card
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/gomonobold"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// size of a card in pixels, the ratio link previews expect
+const (
+	Width  = 1200
+	Height = 630
+)
+
+// MaxLines is the number of lines of code drawn on a card
+const MaxLines = 8
+
+// version is part of every Key, bump it when the layout changes
+const version = "1"
+
+// spaces a tab is drawn as
+const tabWidth = 4
+
+// colours of the site stylesheet
+var (
+	background = color.RGBA{0xF1, 0xF3, 0xF6, 0xFF}
+	panel      = color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}
+	border     = color.RGBA{0xE4, 0xE5, 0xE7, 0xFF}
+	text       = color.RGBA{0x34, 0x49, 0x5E, 0xFF}
+	muted      = color.RGBA{0x6A, 0x6C, 0x6F, 0xFF}
+	faint      = color.RGBA{0xA0, 0xA3, 0xA6, 0xFF}
+	accent     = color.RGBA{0x62, 0xCB, 0x31, 0xFF}
+)
+
+// fonts are parsed once, faces are made per card as they aren't safe for concurrent use
+var regular, bold *opentype.Font
+
+func init() {
+	var err error
+	if regular, err = opentype.Parse(gomono.TTF); err != nil {
+		panic(err)
+	}
+	if bold, err = opentype.Parse(gomonobold.TTF); err != nil {
+		panic(err)
+	}
+}
+
+// Card is what a preview image shows
+type Card struct {
+	Title string
+	// line under the title, like the language and author
+	Subtitle string
+	// code of which the first MaxLines lines are drawn
+	Code string
+	// drawn in place of the code when there is none
+	Notice string
+}
+
+// Key identifies the image of a card, for caching it
+// it changes whenever anything drawn on the card does
+func (c Card) Key() string {
+	return hash(version, c.Title, c.Subtitle, c.Code, c.Notice)
+}
+
+// hash returns a hex digest of fields
+func hash(fields ...string) string {
+	h := sha256.New()
+	for _, s := range fields {
+		// lengths keep fields from running into each other
+		h.Write([]byte{byte(len(s) >> 24), byte(len(s) >> 16), byte(len(s) >> 8), byte(len(s))})
+		h.Write([]byte(s))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Render draws a card as a PNG image
+func Render(c Card) ([]byte, error) {
+	titleFace, err := newFace(bold, 52)
+	if err != nil {
+		return nil, err
+	}
+	defer titleFace.Close()
+	subtitleFace, err := newFace(regular, 28)
+	if err != nil {
+		return nil, err
+	}
+	defer subtitleFace.Close()
+	codeFace, err := newFace(regular, 26)
+	if err != nil {
+		return nil, err
+	}
+	defer codeFace.Close()
+	footerFace, err := newFace(bold, 28)
+	if err != nil {
+		return nil, err
+	}
+	defer footerFace.Close()
+
+	img := image.NewRGBA(image.Rect(0, 0, Width, Height))
+	fill(img, img.Bounds(), background)
+	fill(img, image.Rect(0, 0, Width, 8), accent)
+
+	const margin = 64
+	textWidth := fixed.I(Width - 2*margin)
+	drawText(img, titleFace, text, margin, 112, fit(titleFace, c.Title, textWidth))
+	drawText(img, subtitleFace, muted, margin, 160, fit(subtitleFace, c.Subtitle, textWidth))
+
+	// code panel with a one pixel border
+	box := image.Rect(margin, 200, Width-margin, 560)
+	fill(img, box, border)
+	fill(img, box.Inset(1), panel)
+
+	lines := codeLines(c.Code)
+	if len(lines) == 0 {
+		drawText(img, subtitleFace, muted, box.Min.X+32, box.Min.Y+box.Dy()/2+10, fit(subtitleFace, c.Notice, fixed.I(box.Dx()-64)))
+	}
+	const lineHeight = 40
+	numberWidth := font.MeasureString(codeFace, "00 ").Ceil()
+	for i, line := range lines {
+		y := box.Min.Y + 48 + i*lineHeight
+		drawText(img, codeFace, faint, box.Min.X+24, y, fmt.Sprintf("%2d", i+1))
+		x := box.Min.X + 24 + numberWidth
+		drawText(img, codeFace, text, x, y, fit(codeFace, line, fixed.I(box.Max.X-24-x)))
+	}
+
+	drawText(img, footerFace, accent, margin, 608, "Snippetbox")
+
+	var buf bytes.Buffer
+	if err = png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// newFace returns a face of f at size pixels
+func newFace(f *opentype.Font, size float64) (font.Face, error) {
+	return opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+}
+
+// codeLines returns the first MaxLines lines of code with tabs expanded
+// trailing blank lines are dropped
+func codeLines(code string) []string {
+	code = strings.ReplaceAll(code, "\r\n", "\n")
+	code = strings.TrimRight(code, " \t\n")
+	if code == "" {
+		return nil
+	}
+	lines := strings.SplitN(code, "\n", MaxLines+1)
+	if len(lines) > MaxLines {
+		lines = lines[:MaxLines]
+	}
+	for i, line := range lines {
+		lines[i] = strings.ReplaceAll(line, "\t", strings.Repeat(" ", tabWidth))
+	}
+	return lines
+}
+
+// fit shortens s with an ellipsis until it is at most width wide
+func fit(face font.Face, s string, width fixed.Int26_6) string {
+	if font.MeasureString(face, s) <= width {
+		return s
+	}
+	runes := []rune(s)
+	// the fonts are monospaced, so runes past the column budget can never fit
+	// cutting them first keeps long lines from being measured over and over
+	if advance, ok := face.GlyphAdvance('0'); ok && advance > 0 {
+		if columns := int(width / advance); len(runes) > columns {
+			runes = runes[:columns]
+		}
+	}
+	for len(runes) > 0 {
+		runes = runes[:len(runes)-1]
+		if t := strings.TrimRight(string(runes), " ") + "…"; font.MeasureString(face, t) <= width {
+			return t
+		}
+	}
+	return ""
+}
+
+// fill paints a rectangle in a solid colour
+func fill(img draw.Image, r image.Rectangle, c color.Color) {
+	draw.Draw(img, r, image.NewUniform(c), image.Point{}, draw.Src)
+}
+
+// drawText writes s with its baseline starting at x, y
+func drawText(img draw.Image, face font.Face, c color.Color, x, y int, s string) {
+	d := &font.Drawer{
+		Dst:  img,
+		Src:  image.NewUniform(c),
+		Face: face,
+		Dot:  fixed.P(x, y),
+	}
+	d.DrawString(s)
+}
@@ -0,0 +1,104 @@
+package card
+
+import (
+	"bytes"
+	"image/png"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/image/font"
+)
+
+func TestRender(t *testing.T) {
+	cards := []Card{
+		{Title: "Hello", Subtitle: "main.go · Go · by Alice", Code: "package main\n\nfunc main() {\n\tprintln(\"hi\")\n}\n"},
+		{Title: "Secret", Subtitle: "Plain text · by Bob", Notice: "This snippet is protected by a passphrase."},
+	}
+
+	for _, c := range cards {
+		t.Run(c.Title, func(t *testing.T) {
+			b, err := Render(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			img, err := png.Decode(bytes.NewReader(b))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if size := img.Bounds().Size(); size.X != Width || size.Y != Height {
+				t.Errorf("want %dx%d; got %dx%d", Width, Height, size.X, size.Y)
+			}
+		})
+	}
+}
+
+func TestKey(t *testing.T) {
+	c := Card{Title: "Hello", Subtitle: "Go", Code: "package main"}
+
+	if c.Key() != c.Key() {
+		t.Errorf("want the same card to have the same key")
+	}
+
+	changed := []Card{
+		{Title: "Hello!", Subtitle: "Go", Code: "package main"},
+		{Title: "Hello", Subtitle: "Go", Code: "package frog"},
+		{Title: "Hello", Subtitle: "Go", Code: "package main", Notice: "Gone"},
+		// fields don't run into each other
+		{Title: "HelloGo", Code: "package main"},
+	}
+	for _, other := range changed {
+		if other.Key() == c.Key() {
+			t.Errorf("want %+v to have a different key", other)
+		}
+	}
+}
+
+func TestFit(t *testing.T) {
+	face, err := newFace(regular, 26)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer face.Close()
+
+	width := font.MeasureString(face, "0123456789")
+
+	if got := fit(face, "short", width); got != "short" {
+		t.Errorf("want %q; got %q", "short", got)
+	}
+	if got := fit(face, "0123456789abc", width); got != "012345678…" {
+		t.Errorf("want %q; got %q", "012345678…", got)
+	}
+
+	// a line near the size limit of a snippet is cut without measuring every prefix
+	long := strings.Repeat("x", 64<<10)
+	start := time.Now()
+	if got := fit(face, long, width); got != "xxxxxxxxx…" {
+		t.Errorf("want %q; got %q", "xxxxxxxxx…", got)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("want a long line fitted quickly; took %s", elapsed)
+	}
+}
+
+func TestCodeLines(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		want []string
+	}{
+		{"Tabs", "a\n\tb\r\n", []string{"a", "    b"}},
+		{"Blank", " \n\n", nil},
+		{"Long", "1\n2\n3\n4\n5\n6\n7\n8\n9\n10", []string{"1", "2", "3", "4", "5", "6", "7", "8"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := codeLines(tt.code); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("want %q; got %q", tt.want, got)
+			}
+		})
+	}
+}
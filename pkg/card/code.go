@@ -0,0 +1,375 @@
+package card
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/styles"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gomonobolditalic"
+	"golang.org/x/image/font/gofont/gomonoitalic"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+
+	"robert-tu.net/snippetbox/pkg/highlight"
+)
+
+// limits on code images, in pixels
+const (
+	MinWidth       = 320
+	MaxWidth       = 2400
+	MaxPadding     = 128
+	DefaultPadding = 48
+)
+
+// MaxCodeLines is the number of lines drawn in a code image, longer code is cut off
+const MaxCodeLines = 80
+
+// Themes lists the chroma styles code can be drawn in, the first is the default
+var Themes = []string{"github", "github-dark", "monokai", "dracula", "nord", "solarized-light", "solarized-dark"}
+
+// ValidTheme reports whether name is one of Themes
+func ValidTheme(name string) bool {
+	for _, t := range Themes {
+		if t == name {
+			return true
+		}
+	}
+	return false
+}
+
+// geometry of the window around the code, in pixels
+const (
+	codeSize       = 20
+	codeLineHeight = 30
+	titleSize      = 16
+	barHeight      = 40
+	// space between the window edge and the code
+	windowInset  = 20
+	cornerRadius = 8
+	dotRadius    = 6
+)
+
+// colours outside the theme
+var (
+	backdrop = color.RGBA{0xAB, 0xB8, 0xC3, 0xFF}
+	dots     = []color.RGBA{{0xFF, 0x5F, 0x56, 0xFF}, {0xFF, 0xBD, 0x2E, 0xFF}, {0x27, 0xC9, 0x3F, 0xFF}}
+)
+
+var italic, boldItalic *opentype.Font
+
+func init() {
+	var err error
+	if italic, err = opentype.Parse(gomonoitalic.TTF); err != nil {
+		panic(err)
+	}
+	if boldItalic, err = opentype.Parse(gomonobolditalic.TTF); err != nil {
+		panic(err)
+	}
+}
+
+// Code is a snippet drawn in a window frame, for slides and posts
+type Code struct {
+	// shown in the title bar
+	Title string
+	Code  string
+	// chroma lexer name, as in highlight.Languages
+	Language string
+	// one of Themes, empty for the default
+	Theme string
+	// space around the window
+	Padding int
+	// of the whole image, 0 fits the longest line
+	Width int
+}
+
+// Key identifies the image of code in a format, for caching it
+func (c Code) Key(format string) string {
+	return hash(version, format, c.Title, c.Code, c.Language, c.Theme, strconv.Itoa(c.Padding), strconv.Itoa(c.Width))
+}
+
+// codeLayout is where everything of a code image goes
+type codeLayout struct {
+	width, height int
+	window        image.Rectangle
+	style         *chroma.Style
+	background    color.RGBA
+	foreground    color.RGBA
+	// colour of the title
+	muted     color.RGBA
+	title     string
+	lines     [][]highlight.Token
+	charWidth fixed.Int26_6
+}
+
+// layoutCode sizes the image and cuts lines that don't fit
+func layoutCode(c Code) (*codeLayout, error) {
+	if c.Padding < 0 || c.Padding > MaxPadding {
+		return nil, fmt.Errorf("card: padding %d out of range", c.Padding)
+	}
+	if c.Width != 0 && (c.Width < MinWidth || c.Width > MaxWidth) {
+		return nil, fmt.Errorf("card: width %d out of range", c.Width)
+	}
+	theme := c.Theme
+	if theme == "" {
+		theme = Themes[0]
+	}
+	if !ValidTheme(theme) {
+		return nil, fmt.Errorf("card: unknown theme %q", c.Theme)
+	}
+
+	code := strings.ReplaceAll(strings.TrimRight(c.Code, " \t\r\n"), "\t", strings.Repeat(" ", tabWidth))
+	lines, err := highlight.Tokens(code, c.Language)
+	if err != nil {
+		return nil, err
+	}
+	if len(lines) > MaxCodeLines {
+		lines = lines[:MaxCodeLines]
+	}
+
+	face, err := newFace(regular, codeSize)
+	if err != nil {
+		return nil, err
+	}
+	defer face.Close()
+
+	l := &codeLayout{style: styles.Get(theme), lines: lines}
+	// the font is monospaced
+	l.charWidth = font.MeasureString(face, "0")
+
+	l.width = c.Width
+	if l.width == 0 {
+		columns := 0
+		for _, line := range lines {
+			if n := lineLength(line); n > columns {
+				columns = n
+			}
+		}
+		l.width = 2*c.Padding + 2*windowInset + (l.charWidth * fixed.Int26_6(columns)).Ceil()
+		if l.width < MinWidth {
+			l.width = MinWidth
+		}
+		if l.width > MaxWidth {
+			l.width = MaxWidth
+		}
+	}
+	l.height = 2*c.Padding + barHeight + len(lines)*codeLineHeight + windowInset
+	l.window = image.Rect(c.Padding, c.Padding, l.width-c.Padding, l.height-c.Padding)
+
+	columns := fixed.I(l.window.Dx()-2*windowInset) / l.charWidth
+	for i, line := range lines {
+		l.lines[i] = cutLine(line, int(columns))
+	}
+	// the title sits between the dots and the right edge, centred in the window
+	l.title = c.Title
+	titleChar := float64(l.charWidth) / 64 * titleSize / codeSize
+	if n := int(float64(l.window.Dx()-2*80) / titleChar); utf8.RuneCountInString(l.title) > n {
+		l.title = ""
+		if n > 1 {
+			l.title = string([]rune(c.Title)[:n-1]) + "…"
+		}
+	}
+
+	bg := l.style.Get(chroma.Background)
+	l.background = rgba(bg.Background, color.RGBA{0xFF, 0xFF, 0xFF, 0xFF})
+	l.foreground = rgba(bg.Colour, color.RGBA{0x00, 0x00, 0x00, 0xFF})
+	l.muted = rgba(l.style.Get(chroma.Comment).Colour, l.foreground)
+	return l, nil
+}
+
+// lineLength counts the characters of a line of tokens
+func lineLength(line []highlight.Token) int {
+	n := 0
+	for _, t := range line {
+		n += utf8.RuneCountInString(t.Text)
+	}
+	return n
+}
+
+// cutLine shortens a line longer than columns characters, ending it with an ellipsis
+func cutLine(line []highlight.Token, columns int) []highlight.Token {
+	if lineLength(line) <= columns {
+		return line
+	}
+	cut := []highlight.Token{}
+	left := columns - 1
+	for _, t := range line {
+		if left <= 0 {
+			break
+		}
+		runes := []rune(t.Text)
+		if len(runes) > left {
+			runes = runes[:left]
+		}
+		cut = append(cut, highlight.Token{Text: string(runes), Type: t.Type})
+		left -= len(runes)
+	}
+	return append(cut, highlight.Token{Text: "…", Type: chroma.Text})
+}
+
+// baseline returns the baseline of line i
+func (l *codeLayout) baseline(i int) int {
+	return l.window.Min.Y + barHeight + i*codeLineHeight + 21
+}
+
+// tokenStyle returns the colour of a token and whether it is bold and italic
+func (l *codeLayout) tokenStyle(t chroma.TokenType) (color.RGBA, bool, bool) {
+	e := l.style.Get(t)
+	return rgba(e.Colour, l.foreground), e.Bold == chroma.Yes, e.Italic == chroma.Yes
+}
+
+// RenderPNG draws code in a window as a PNG image
+func RenderPNG(c Code) ([]byte, error) {
+	l, err := layoutCode(c)
+	if err != nil {
+		return nil, err
+	}
+
+	faces := map[[2]bool]font.Face{}
+	for style, f := range map[[2]bool]*opentype.Font{
+		{false, false}: regular,
+		{true, false}:  bold,
+		{false, true}:  italic,
+		{true, true}:   boldItalic,
+	} {
+		face, err := newFace(f, codeSize)
+		if err != nil {
+			return nil, err
+		}
+		defer face.Close()
+		faces[style] = face
+	}
+	titleFace, err := newFace(regular, titleSize)
+	if err != nil {
+		return nil, err
+	}
+	defer titleFace.Close()
+
+	img := image.NewRGBA(image.Rect(0, 0, l.width, l.height))
+	fill(img, img.Bounds(), backdrop)
+	draw.DrawMask(img, l.window, image.NewUniform(l.background), image.Point{}, roundedRect{l.window, cornerRadius}, l.window.Min, draw.Over)
+
+	for i, c := range dots {
+		center := image.Pt(l.window.Min.X+20+i*20, l.window.Min.Y+barHeight/2)
+		r := image.Rect(center.X-dotRadius, center.Y-dotRadius, center.X+dotRadius, center.Y+dotRadius)
+		draw.DrawMask(img, r, image.NewUniform(c), image.Point{}, roundedRect{r, dotRadius}, r.Min, draw.Over)
+	}
+
+	titleWidth := font.MeasureString(titleFace, l.title).Round()
+	drawText(img, titleFace, l.muted, l.window.Min.X+(l.window.Dx()-titleWidth)/2, l.window.Min.Y+barHeight/2+titleSize/3, l.title)
+
+	for i, line := range l.lines {
+		dot := fixed.P(l.window.Min.X+windowInset, l.baseline(i))
+		for _, t := range line {
+			colour, isBold, isItalic := l.tokenStyle(t.Type)
+			d := &font.Drawer{Dst: img, Src: image.NewUniform(colour), Face: faces[[2]bool{isBold, isItalic}], Dot: dot}
+			d.DrawString(t.Text)
+			// keep to the grid whatever the face
+			dot.X += l.charWidth * fixed.Int26_6(utf8.RuneCountInString(t.Text))
+		}
+	}
+
+	var buf bytes.Buffer
+	if err = png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// RenderSVG draws code in a window as an SVG image
+// fonts aren't embedded, every token is placed on the character grid so any monospace font lines up
+func RenderSVG(c Code) ([]byte, error) {
+	l, err := layoutCode(c)
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n", l.width, l.height, l.width, l.height)
+	fmt.Fprintf(&b, `<style>text { font-family: "Go Mono", ui-monospace, monospace; font-size: %dpx; white-space: pre; }</style>`+"\n", codeSize)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="%s"/>`+"\n", l.width, l.height, svgColour(backdrop))
+	fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%d" height="%d" rx="%d" fill="%s"/>`+"\n",
+		l.window.Min.X, l.window.Min.Y, l.window.Dx(), l.window.Dy(), cornerRadius, svgColour(l.background))
+	for i, c := range dots {
+		fmt.Fprintf(&b, `<circle cx="%d" cy="%d" r="%d" fill="%s"/>`+"\n", l.window.Min.X+20+i*20, l.window.Min.Y+barHeight/2, dotRadius, svgColour(c))
+	}
+	fmt.Fprintf(&b, `<text x="%d" y="%d" fill="%s" font-size="%d" text-anchor="middle">%s</text>`+"\n",
+		l.window.Min.X+l.window.Dx()/2, l.window.Min.Y+barHeight/2+titleSize/3, svgColour(l.muted), titleSize, escape(l.title))
+
+	charWidth := float64(l.charWidth) / 64
+	for i, line := range l.lines {
+		if len(line) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, `<text y="%d">`, l.baseline(i))
+		column := 0
+		for _, t := range line {
+			colour, isBold, isItalic := l.tokenStyle(t.Type)
+			fmt.Fprintf(&b, `<tspan x="%s" fill="%s"`, strconv.FormatFloat(float64(l.window.Min.X+windowInset)+charWidth*float64(column), 'f', 2, 64), svgColour(colour))
+			if isBold {
+				b.WriteString(` font-weight="bold"`)
+			}
+			if isItalic {
+				b.WriteString(` font-style="italic"`)
+			}
+			fmt.Fprintf(&b, `>%s</tspan>`, escape(t.Text))
+			column += utf8.RuneCountInString(t.Text)
+		}
+		b.WriteString("</text>\n")
+	}
+	b.WriteString("</svg>\n")
+	return b.Bytes(), nil
+}
+
+// roundedRect is an alpha mask of a rectangle with rounded corners
+type roundedRect struct {
+	r      image.Rectangle
+	radius int
+}
+
+func (m roundedRect) ColorModel() color.Model { return color.AlphaModel }
+
+func (m roundedRect) Bounds() image.Rectangle { return m.r }
+
+// At antialiases the corners by the distance of a pixel centre from the corner circle
+func (m roundedRect) At(x, y int) color.Color {
+	if !(image.Point{x, y}.In(m.r)) {
+		return color.Alpha{}
+	}
+	radius := float64(m.radius)
+	px, py := float64(x)+0.5, float64(y)+0.5
+	cx := math.Max(float64(m.r.Min.X)+radius, math.Min(px, float64(m.r.Max.X)-radius))
+	cy := math.Max(float64(m.r.Min.Y)+radius, math.Min(py, float64(m.r.Max.Y)-radius))
+	coverage := radius + 0.5 - math.Hypot(px-cx, py-cy)
+	return color.Alpha{uint8(255 * math.Max(0, math.Min(1, coverage)))}
+}
+
+// rgba converts a chroma colour, returning def if it isn't set
+func rgba(c chroma.Colour, def color.RGBA) color.RGBA {
+	if !c.IsSet() {
+		return def
+	}
+	return color.RGBA{c.Red(), c.Green(), c.Blue(), 0xFF}
+}
+
+// svgColour formats a colour for SVG
+func svgColour(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// escape makes text safe inside an SVG element
+func escape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
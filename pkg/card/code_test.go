@@ -0,0 +1,96 @@
+package card
+
+import (
+	"bytes"
+	"image/png"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/chroma/v2"
+
+	"robert-tu.net/snippetbox/pkg/highlight"
+)
+
+func TestRenderCode(t *testing.T) {
+	c := Code{Title: "main.go", Code: "package main\n\n// <b> & co\nfunc main() {}\n", Language: "go", Padding: 10, Width: 400}
+
+	b, err := RenderPNG(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantHeight := 2*c.Padding + barHeight + 4*codeLineHeight + windowInset
+	if size := img.Bounds().Size(); size.X != 400 || size.Y != wantHeight {
+		t.Errorf("want 400x%d; got %dx%d", wantHeight, size.X, size.Y)
+	}
+	// the padding shows the backdrop
+	if got := img.At(0, 0); got != backdrop {
+		t.Errorf("want backdrop %v; got %v", backdrop, got)
+	}
+
+	b, err = RenderSVG(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	svg := string(b)
+	for _, want := range []string{`<svg xmlns="http://www.w3.org/2000/svg" width="400"`, `&lt;b&gt; &amp; co`, `>package</tspan>`} {
+		if !strings.Contains(svg, want) {
+			t.Errorf("want svg to contain %q", want)
+		}
+	}
+}
+
+func TestRenderCodeOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		code    Code
+		wantErr bool
+	}{
+		{"Defaults", Code{Code: "x"}, false},
+		{"Theme", Code{Code: "x", Theme: "dracula"}, false},
+		{"Unknown theme", Code{Code: "x", Theme: "neon"}, true},
+		{"Negative padding", Code{Code: "x", Padding: -1}, true},
+		{"Large padding", Code{Code: "x", Padding: MaxPadding + 1}, true},
+		{"Narrow", Code{Code: "x", Width: MinWidth - 1}, true},
+		{"Wide", Code{Code: "x", Width: MaxWidth + 1}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := RenderSVG(tt.code)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("want error %t; got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestCodeKey(t *testing.T) {
+	c := Code{Code: "x", Language: "go", Theme: "github", Padding: 48}
+
+	if c.Key("png") == c.Key("svg") {
+		t.Errorf("want formats to have different keys")
+	}
+	other := c
+	other.Padding = 4
+	if c.Key("png") == other.Key("png") {
+		t.Errorf("want padding to change the key")
+	}
+}
+
+func TestCutLine(t *testing.T) {
+	line := []highlight.Token{{Text: "func", Type: chroma.Keyword}, {Text: " main()", Type: chroma.Text}}
+
+	if got := cutLine(line, 11); !reflect.DeepEqual(got, line) {
+		t.Errorf("want line that fits to be kept; got %+v", got)
+	}
+
+	want := []highlight.Token{{Text: "func", Type: chroma.Keyword}, {Text: " ", Type: chroma.Text}, {Text: "…", Type: chroma.Text}}
+	if got := cutLine(line, 6); !reflect.DeepEqual(got, want) {
+		t.Errorf("want %+v; got %+v", want, got)
+	}
+}
@@ -0,0 +1,378 @@
+// Package diff computes line-based differences between two texts
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Op describes how a line changed
+type Op int
+
+const (
+	Equal Op = iota
+	Delete
+	Insert
+)
+
+// String returns the name of the operation, used as a CSS class in templates
+func (op Op) String() string {
+	switch op {
+	case Delete:
+		return "delete"
+	case Insert:
+		return "insert"
+	default:
+		return "equal"
+	}
+}
+
+// Line is a single line of a diff
+// Old and New hold 1-based line numbers, 0 when the line is absent on that side
+type Line struct {
+	Op   Op
+	Text string
+	Old  int
+	New  int
+}
+
+// Hunk is a group of changed lines surrounded by context
+type Hunk struct {
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+	Lines    []Line
+}
+
+// Header returns the @@ range line of a unified diff hunk
+func (h Hunk) Header() string {
+	return fmt.Sprintf("@@ -%s +%s @@", hunkRange(h.OldStart, h.OldLines), hunkRange(h.NewStart, h.NewLines))
+}
+
+// Row pairs the old and new side of a side-by-side diff, either side may be nil
+type Row struct {
+	Left  *Line
+	Right *Line
+}
+
+// split text into lines, normalizing line endings
+func split(s string) []string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// MaxLines is the most lines either side of a diff may have
+// callers should check TooLarge before computing a diff
+const MaxLines = 5000
+
+// TooLarge reports whether either text has too many lines to diff
+func TooLarge(a, b string) bool {
+	return strings.Count(a, "\n") > MaxLines || strings.Count(b, "\n") > MaxLines
+}
+
+// Lines returns a minimal edit script turning a into b
+// uses the linear space variant of Myers' O(ND) algorithm
+func Lines(a, b string) []Line {
+	d := &differ{a: split(a), b: split(b)}
+	d.lines = make([]Line, 0, len(d.a)+len(d.b))
+	d.compare(0, len(d.a), 0, len(d.b))
+	return d.lines
+}
+
+// differ accumulates the edit script between two sets of lines in document order
+type differ struct {
+	a, b  []string
+	lines []Line
+}
+
+// compare appends the edit script for a[x0:x1] and b[y0:y1]
+func (d *differ) compare(x0, x1, y0, y1 int) {
+	// common prefix
+	for x0 < x1 && y0 < y1 && d.a[x0] == d.b[y0] {
+		d.lines = append(d.lines, Line{Op: Equal, Text: d.a[x0], Old: x0 + 1, New: y0 + 1})
+		x0++
+		y0++
+	}
+	// common suffix, appended once the middle is done
+	suffix := 0
+	for x1 > x0 && y1 > y0 && d.a[x1-1] == d.b[y1-1] {
+		x1--
+		y1--
+		suffix++
+	}
+
+	switch {
+	case x0 == x1:
+		for j := y0; j < y1; j++ {
+			d.lines = append(d.lines, Line{Op: Insert, Text: d.b[j], New: j + 1})
+		}
+	case y0 == y1:
+		for i := x0; i < x1; i++ {
+			d.lines = append(d.lines, Line{Op: Delete, Text: d.a[i], Old: i + 1})
+		}
+	default:
+		x, y := d.bisect(x0, x1, y0, y1)
+		d.compare(x0, x, y0, y)
+		d.compare(x, x1, y, y1)
+	}
+
+	for i := 0; i < suffix; i++ {
+		d.lines = append(d.lines, Line{Op: Equal, Text: d.a[x1+i], Old: x1 + i + 1, New: y1 + i + 1})
+	}
+}
+
+// bisect finds where the forward and reverse paths through a[x0:x1] and
+// b[y0:y1] meet, only keeping the furthest reaching x of each diagonal
+func (d *differ) bisect(x0, x1, y0, y1 int) (int, int) {
+	n, m := x1-x0, y1-y0
+	maxD := (n + m + 1) / 2
+	offset := maxD
+	size := 2*maxD + 2
+
+	// vf and vr hold the furthest x reached on each diagonal going forwards
+	// from the start and backwards from the end, -1 when not reached yet
+	vf := make([]int, size)
+	vr := make([]int, size)
+	for i := range vf {
+		vf[i] = -1
+		vr[i] = -1
+	}
+	vf[offset+1] = 0
+	vr[offset+1] = 0
+
+	delta := n - m
+	// with an odd delta the paths meet while stepping forwards
+	front := delta%2 != 0
+	// trim diagonals that ran off the edges
+	kfStart, kfEnd, krStart, krEnd := 0, 0, 0, 0
+
+	for step := 0; step < maxD; step++ {
+		for k := -step + kfStart; k <= step-kfEnd; k += 2 {
+			var x int
+			if k == -step || (k != step && vf[offset+k-1] < vf[offset+k+1]) {
+				x = vf[offset+k+1]
+			} else {
+				x = vf[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && d.a[x0+x] == d.b[y0+y] {
+				x++
+				y++
+			}
+			vf[offset+k] = x
+			switch {
+			case x > n:
+				kfEnd += 2
+			case y > m:
+				kfStart += 2
+			case front:
+				kr := offset + delta - k
+				if kr >= 0 && kr < size && vr[kr] != -1 && x >= n-vr[kr] {
+					return x0 + x, y0 + y
+				}
+			}
+		}
+
+		for k := -step + krStart; k <= step-krEnd; k += 2 {
+			var x int
+			if k == -step || (k != step && vr[offset+k-1] < vr[offset+k+1]) {
+				x = vr[offset+k+1]
+			} else {
+				x = vr[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && d.a[x1-x-1] == d.b[y1-y-1] {
+				x++
+				y++
+			}
+			vr[offset+k] = x
+			switch {
+			case x > n:
+				krEnd += 2
+			case y > m:
+				krStart += 2
+			case !front:
+				kf := offset + delta - k
+				if kf >= 0 && kf < size && vf[kf] != -1 {
+					fx := vf[kf]
+					if fx >= n-x {
+						return x0 + fx, y0 + fx - (kf - offset)
+					}
+				}
+			}
+		}
+	}
+
+	// no common lines, delete all of a then insert all of b
+	return x1, y0
+}
+
+// Changed reports whether a diff contains any insertions or deletions
+func Changed(lines []Line) bool {
+	for _, l := range lines {
+		if l.Op != Equal {
+			return true
+		}
+	}
+	return false
+}
+
+// Hunks groups changed lines with up to context unchanged lines around them
+func Hunks(lines []Line, context int) []Hunk {
+	var hunks []Hunk
+	var h *Hunk
+	// index of the last changed line added to h
+	last := -1
+
+	for idx, l := range lines {
+		if l.Op == Equal {
+			continue
+		}
+		start := idx - context
+		if start < 0 {
+			start = 0
+		}
+		if h != nil && start <= last+context+1 {
+			// close enough to extend the current hunk
+			start = last + 1
+		} else {
+			if h != nil {
+				h.Lines = append(h.Lines, trailing(lines, last, context)...)
+				hunks = append(hunks, *h)
+			}
+			h = &Hunk{}
+		}
+		h.Lines = append(h.Lines, lines[start:idx+1]...)
+		last = idx
+	}
+	if h != nil {
+		h.Lines = append(h.Lines, trailing(lines, last, context)...)
+		hunks = append(hunks, *h)
+	}
+
+	for i := range hunks {
+		hunks[i].count(lines)
+	}
+	return hunks
+}
+
+// trailing returns up to context lines after index last
+func trailing(lines []Line, last, context int) []Line {
+	end := last + 1 + context
+	if end > len(lines) {
+		end = len(lines)
+	}
+	return lines[last+1 : end]
+}
+
+// count fills in the hunk ranges from its lines
+func (h *Hunk) count(all []Line) {
+	for _, l := range h.Lines {
+		switch l.Op {
+		case Equal:
+			h.OldLines++
+			h.NewLines++
+		case Delete:
+			h.OldLines++
+		case Insert:
+			h.NewLines++
+		}
+		if h.OldStart == 0 && l.Old > 0 {
+			h.OldStart = l.Old
+		}
+		if h.NewStart == 0 && l.New > 0 {
+			h.NewStart = l.New
+		}
+	}
+	// an empty range refers to the line before it
+	if h.OldLines == 0 {
+		h.OldStart = lineBefore(all, h.Lines[0], func(l Line) int { return l.Old })
+	}
+	if h.NewLines == 0 {
+		h.NewStart = lineBefore(all, h.Lines[0], func(l Line) int { return l.New })
+	}
+}
+
+// lineBefore finds the last line number on one side preceding first
+func lineBefore(all []Line, first Line, side func(Line) int) int {
+	n := 0
+	for _, l := range all {
+		if l == first {
+			break
+		}
+		if side(l) > 0 {
+			n = side(l)
+		}
+	}
+	return n
+}
+
+func hunkRange(start, lines int) string {
+	if lines == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, lines)
+}
+
+// Unified renders a diff in unified format with three lines of context
+func Unified(fromName, toName string, lines []Line) string {
+	hunks := Hunks(lines, 3)
+	if len(hunks) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", fromName, toName)
+	for _, h := range hunks {
+		b.WriteString(h.Header())
+		b.WriteByte('\n')
+		for _, l := range h.Lines {
+			switch l.Op {
+			case Equal:
+				b.WriteByte(' ')
+			case Delete:
+				b.WriteByte('-')
+			case Insert:
+				b.WriteByte('+')
+			}
+			b.WriteString(l.Text)
+			b.WriteByte('\n')
+		}
+	}
+	return b.String()
+}
+
+// SideBySide pairs deletions with the insertions that replace them
+func SideBySide(lines []Line) []Row {
+	var rows []Row
+	for i := 0; i < len(lines); {
+		if lines[i].Op == Equal {
+			rows = append(rows, Row{Left: &lines[i], Right: &lines[i]})
+			i++
+			continue
+		}
+		// collect a run of deletions followed by a run of insertions
+		var dels, ins []*Line
+		for ; i < len(lines) && lines[i].Op == Delete; i++ {
+			dels = append(dels, &lines[i])
+		}
+		for ; i < len(lines) && lines[i].Op == Insert; i++ {
+			ins = append(ins, &lines[i])
+		}
+		for n := 0; n < len(dels) || n < len(ins); n++ {
+			var row Row
+			if n < len(dels) {
+				row.Left = dels[n]
+			}
+			if n < len(ins) {
+				row.Right = ins[n]
+			}
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
@@ -0,0 +1,189 @@
+package diff
+
+import (
+	"fmt"
+	"math/rand"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		want string
+	}{
+		{
+			name: "Identical",
+			a:    "one\ntwo\n",
+			b:    "one\ntwo\n",
+			want: "",
+		},
+		{
+			name: "Changed line",
+			a:    "one\ntwo\nthree\n",
+			b:    "one\n2\nthree\n",
+			want: "--- a\n+++ b\n@@ -1,3 +1,3 @@\n one\n-two\n+2\n three\n",
+		},
+		{
+			name: "Added to empty",
+			a:    "",
+			b:    "one\ntwo",
+			want: "--- a\n+++ b\n@@ -0,0 +1,2 @@\n+one\n+two\n",
+		},
+		{
+			name: "CRLF line endings",
+			a:    "one\r\ntwo\r\n",
+			b:    "one\ntwo\n",
+			want: "",
+		},
+		{
+			name: "Separate hunks",
+			a:    "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n",
+			b:    "one\n2\n3\n4\n5\n6\n7\n8\n9\nten\n",
+			want: "--- a\n+++ b\n@@ -1,4 +1,4 @@\n-1\n+one\n 2\n 3\n 4\n@@ -7,4 +7,4 @@\n 7\n 8\n 9\n-10\n+ten\n",
+		},
+		{
+			name: "Deleted line",
+			a:    "one\ntwo\nthree\n",
+			b:    "one\nthree\n",
+			want: "--- a\n+++ b\n@@ -1,3 +1,2 @@\n one\n-two\n three\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Unified("a", "b", Lines(tt.a, tt.b))
+
+			if got != tt.want {
+				t.Errorf("want %q; got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSideBySide(t *testing.T) {
+	rows := SideBySide(Lines("one\ntwo\nthree\n", "one\n2\n2.5\nthree\n"))
+
+	if len(rows) != 4 {
+		t.Fatalf("want %d rows; got %d", 4, len(rows))
+	}
+
+	// replaced line is paired with its replacement
+	if rows[1].Left == nil || rows[1].Left.Text != "two" || rows[1].Right == nil || rows[1].Right.Text != "2" {
+		t.Errorf("want row 1 to pair %q with %q; got %+v", "two", "2", rows[1])
+	}
+
+	// extra insertion has no left side
+	if rows[2].Left != nil || rows[2].Right == nil || rows[2].Right.Text != "2.5" {
+		t.Errorf("want row 2 to only contain %q; got %+v", "2.5", rows[2])
+	}
+}
+
+// apply rebuilds both sides of a diff
+func apply(lines []Line) (string, string) {
+	var a, b strings.Builder
+	for _, l := range lines {
+		if l.Op != Insert {
+			a.WriteString(l.Text + "\n")
+		}
+		if l.Op != Delete {
+			b.WriteString(l.Text + "\n")
+		}
+	}
+	return a.String(), b.String()
+}
+
+// edits counts the insertions and deletions in a diff
+func edits(lines []Line) int {
+	n := 0
+	for _, l := range lines {
+		if l.Op != Equal {
+			n++
+		}
+	}
+	return n
+}
+
+func TestLinesMinimal(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	random := func() string {
+		var b strings.Builder
+		for i := rnd.Intn(12); i > 0; i-- {
+			fmt.Fprintf(&b, "%c\n", 'a'+rnd.Intn(3))
+		}
+		return b.String()
+	}
+
+	for i := 0; i < 500; i++ {
+		a, b := random(), random()
+		lines := Lines(a, b)
+
+		gotA, gotB := apply(lines)
+		if gotA != a || gotB != b {
+			t.Fatalf("diff of %q and %q rebuilds %q and %q", a, b, gotA, gotB)
+		}
+
+		// the shortest edit script is len(a)+len(b)-2*lcs(a, b) long
+		x, y := split(a), split(b)
+		lcs := make([][]int, len(x)+1)
+		for i := range lcs {
+			lcs[i] = make([]int, len(y)+1)
+		}
+		for i := len(x) - 1; i >= 0; i-- {
+			for j := len(y) - 1; j >= 0; j-- {
+				if x[i] == y[j] {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else if lcs[i+1][j] > lcs[i][j+1] {
+					lcs[i][j] = lcs[i+1][j]
+				} else {
+					lcs[i][j] = lcs[i][j+1]
+				}
+			}
+		}
+		if want := len(x) + len(y) - 2*lcs[0][0]; edits(lines) != want {
+			t.Fatalf("diff of %q and %q: want %d edits; got %d", a, b, want, edits(lines))
+		}
+	}
+}
+
+func TestLinesLarge(t *testing.T) {
+	// every other line differs, far apart from any other match
+	var a, b strings.Builder
+	for i := 0; i < 3000; i++ {
+		fmt.Fprintf(&a, "same %d\nold %d\n", i, i)
+		fmt.Fprintf(&b, "same %d\nnew %d\n", i, i)
+	}
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	lines := Lines(a.String(), b.String())
+	runtime.ReadMemStats(&after)
+
+	if n := edits(lines); n != 6000 {
+		t.Errorf("want %d edits; got %d", 6000, n)
+	}
+	gotA, gotB := apply(lines)
+	if gotA != a.String() || gotB != b.String() {
+		t.Error("diff does not rebuild its inputs")
+	}
+
+	// space is linear in the input, not quadratic
+	if alloc := after.TotalAlloc - before.TotalAlloc; alloc > 16<<20 {
+		t.Errorf("want at most %d bytes allocated; got %d", 16<<20, alloc)
+	}
+}
+
+func TestTooLarge(t *testing.T) {
+	small := strings.Repeat("line\n", MaxLines)
+	large := strings.Repeat("line\n", MaxLines+1)
+
+	if TooLarge(small, small) {
+		t.Errorf("want %d lines to be diffable", MaxLines)
+	}
+	if !TooLarge(small, large) || !TooLarge(large, small) {
+		t.Errorf("want %d lines to be too large", MaxLines+1)
+	}
+}
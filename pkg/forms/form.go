@@ -4,13 +4,18 @@ import (
 	"fmt"
 	"net/url"
 	"regexp"
+	"strconv"
 	"strings"
+	"unicode"
 	"unicode/utf8"
 )
 
 // use regex.MustCompile() to check email address
 var EmailRX = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+\\/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
 
+// use regex.MustCompile() to check tag names
+var TagRX = regexp.MustCompile(`^[a-z0-9][a-z0-9+#._-]*$`)
+
 // create custom form struct
 type Form struct {
 	url.Values
@@ -71,6 +76,18 @@ func (f *Form) PermittedValues(field string, opts ...string) {
 	f.Errors.Add(field, "This field is invalid")
 }
 
+// define IntRange to check a field is a whole number between min and max
+func (f *Form) IntRange(field string, min, max int) {
+	value := f.Get(field)
+	if value == "" {
+		return
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < min || n > max {
+		f.Errors.Add(field, fmt.Sprintf("This field must be a number from %d to %d", min, max))
+	}
+}
+
 // define MatchesPattern to check specific field matches regex
 func (f *Form) MatchesPattern(field string, pattern *regexp.Regexp) {
 	value := f.Get(field)
@@ -82,6 +99,37 @@ func (f *Form) MatchesPattern(field string, pattern *regexp.Regexp) {
 	}
 }
 
+// define Tags to split a comma or space separated field into lowercase tags
+// duplicates are dropped and the order is kept
+func (f *Form) Tags(field string) []string {
+	tags := []string{}
+	seen := map[string]bool{}
+	split := func(r rune) bool { return r == ',' || unicode.IsSpace(r) }
+	for _, tag := range strings.FieldsFunc(f.Get(field), split) {
+		tag = strings.ToLower(tag)
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// define ValidTags to check a list of tags is short and well formed
+func (f *Form) ValidTags(field string, max, maxLength int) {
+	tags := f.Tags(field)
+	if len(tags) > max {
+		f.Errors.Add(field, fmt.Sprintf("This field has too many tags (max %d)", max))
+		return
+	}
+	for _, tag := range tags {
+		if utf8.RuneCountInString(tag) > maxLength || !TagRX.MatchString(tag) {
+			f.Errors.Add(field, fmt.Sprintf("The tag %q is invalid (use letters, numbers and +#._- up to %d characters)", tag, maxLength))
+			return
+		}
+	}
+}
+
 // define Valid method if no errors
 func (f *Form) Valid() bool {
 	return len(f.Errors) == 0
@@ -0,0 +1,178 @@
+// Package highlight renders source code as syntax highlighted HTML
+// markup uses chroma's short CSS class names so no inline styles are needed
+package highlight
+
+import (
+	"html/template"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/lexers"
+)
+
+// Plaintext is the language of content that isn't highlighted
+const Plaintext = "plaintext"
+
+// Markdown is the language of snippets shown as rendered HTML
+const Markdown = "markdown"
+
+// Language is a language offered when creating a snippet
+type Language struct {
+	// chroma lexer name or alias
+	Name      string
+	Label     string
+	Extension string
+}
+
+// Languages lists the languages a snippet can be written in
+var Languages = []Language{
+	{Plaintext, "Plain text", ".txt"},
+	{"bash", "Bash", ".sh"},
+	{"c", "C", ".c"},
+	{"cpp", "C++", ".cpp"},
+	{"csharp", "C#", ".cs"},
+	{"css", "CSS", ".css"},
+	{"docker", "Dockerfile", ".dockerfile"},
+	{"go", "Go", ".go"},
+	{"html", "HTML", ".html"},
+	{"java", "Java", ".java"},
+	{"javascript", "JavaScript", ".js"},
+	{"json", "JSON", ".json"},
+	{"makefile", "Makefile", ".mk"},
+	{Markdown, "Markdown", ".md"},
+	{"php", "PHP", ".php"},
+	{"python", "Python", ".py"},
+	{"ruby", "Ruby", ".rb"},
+	{"rust", "Rust", ".rs"},
+	{"sql", "SQL", ".sql"},
+	{"toml", "TOML", ".toml"},
+	{"typescript", "TypeScript", ".ts"},
+	{"yaml", "YAML", ".yaml"},
+}
+
+// byLexer maps chroma's canonical lexer names to entries of Languages
+var byLexer = map[string]string{}
+
+func init() {
+	for _, l := range Languages {
+		if lexer := lexers.Get(l.Name); lexer != nil {
+			byLexer[lexer.Config().Name] = l.Name
+		}
+	}
+}
+
+// Names returns the name of every language in Languages
+func Names() []string {
+	names := make([]string, len(Languages))
+	for i, l := range Languages {
+		names[i] = l.Name
+	}
+	return names
+}
+
+// Lookup returns the entry of Languages with the given name
+func Lookup(name string) (Language, bool) {
+	for _, l := range Languages {
+		if l.Name == name {
+			return l, true
+		}
+	}
+	return Language{}, false
+}
+
+// Detect guesses the language of content, falling back to Plaintext
+func Detect(content string) string {
+	if lexer := lexers.Analyse(content); lexer != nil {
+		if name, ok := byLexer[lexer.Config().Name]; ok {
+			return name
+		}
+	}
+	return Plaintext
+}
+
+// ForFilename guesses the language of a file from its name, like main.go or Makefile
+func ForFilename(name string) (string, bool) {
+	if lexer := lexers.Match(name); lexer != nil {
+		if l, ok := byLexer[lexer.Config().Name]; ok {
+			return l, true
+		}
+	}
+	return "", false
+}
+
+// Line is a single highlighted line of code
+type Line struct {
+	Number int
+	HTML   template.HTML
+}
+
+// Lines splits content into highlighted lines
+// unknown languages are rendered as escaped plain text
+func Lines(content, language string) ([]Line, error) {
+	tokenLines, err := Tokens(content, language)
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []Line
+	for i, tokens := range tokenLines {
+		var b strings.Builder
+		for _, token := range tokens {
+			if class := cssClass(token.Type); class != "" {
+				b.WriteString(`<span class="` + class + `">`)
+				b.WriteString(template.HTMLEscapeString(token.Text))
+				b.WriteString(`</span>`)
+			} else {
+				b.WriteString(template.HTMLEscapeString(token.Text))
+			}
+		}
+		lines = append(lines, Line{Number: i + 1, HTML: template.HTML(b.String())})
+	}
+	return lines, nil
+}
+
+// Token is a piece of code of a single token type
+type Token struct {
+	Text string
+	Type chroma.TokenType
+}
+
+// Tokens splits content into lines of tokens without their line endings, for drawing code outside HTML
+// unknown languages are treated as plain text
+func Tokens(content, language string) ([][]Token, error) {
+	lexer := lexers.Get(language)
+	if lexer == nil {
+		lexer = lexers.Get(Plaintext)
+	}
+	lexer = chroma.Coalesce(lexer)
+
+	// lexers expect unix line endings
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	iterator, err := lexer.Tokenise(nil, content)
+	if err != nil {
+		return nil, err
+	}
+
+	var lines [][]Token
+	for _, tokens := range chroma.SplitTokensIntoLines(iterator.Tokens()) {
+		line := []Token{}
+		for _, token := range tokens {
+			text := strings.TrimSuffix(token.Value, "\n")
+			if text != "" {
+				line = append(line, Token{Text: text, Type: token.Type})
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
+
+// cssClass returns chroma's class for a token type, using the closest parent type with one
+func cssClass(t chroma.TokenType) string {
+	for ; t != 0; t = t.Parent() {
+		if class, ok := chroma.StandardTypes[t]; ok {
+			return class
+		}
+	}
+	return chroma.StandardTypes[t]
+}
@@ -0,0 +1,107 @@
+package highlight
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/chroma/v2"
+)
+
+func TestLines(t *testing.T) {
+	lines, err := Lines("package main\n\n// <b>\nfunc main() {}\n", "go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(lines) != 4 {
+		t.Fatalf("want %d lines; got %d", 4, len(lines))
+	}
+
+	if lines[0].Number != 1 || !strings.Contains(string(lines[0].HTML), `<span class="kn">package</span>`) {
+		t.Errorf("want keyword markup on line 1; got %q", lines[0].HTML)
+	}
+
+	// content is escaped
+	if !strings.Contains(string(lines[2].HTML), "&lt;b&gt;") {
+		t.Errorf("want escaped comment on line 3; got %q", lines[2].HTML)
+	}
+
+	// unknown languages fall back to plain text
+	lines, err = Lines("<script>", "blah")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 1 || lines[0].HTML != "&lt;script&gt;" {
+		t.Errorf("want escaped plain text; got %+v", lines)
+	}
+}
+
+func TestTokens(t *testing.T) {
+	lines, err := Tokens("package main\r\n\nfunc main() {}\n", "go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(lines) != 3 {
+		t.Fatalf("want %d lines; got %d", 3, len(lines))
+	}
+	if len(lines[0]) == 0 || lines[0][0] != (Token{Text: "package", Type: chroma.KeywordNamespace}) {
+		t.Errorf("want keyword token first; got %+v", lines[0])
+	}
+	if len(lines[1]) != 0 {
+		t.Errorf("want empty line 2; got %+v", lines[1])
+	}
+	for _, line := range lines {
+		for _, token := range line {
+			if strings.ContainsAny(token.Text, "\r\n") {
+				t.Errorf("want tokens without line endings; got %q", token.Text)
+			}
+		}
+	}
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"Go", "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(\"hi\")\n}\n", "go"},
+		{"Shell", "#!/bin/bash\necho hi\n", "bash"},
+		{"Prose", "An old silent pond", Plaintext},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Detect(tt.content)
+
+			if got != tt.want {
+				t.Errorf("want %q; got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestForFilename(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		want     string
+		wantOK   bool
+	}{
+		{"Go", "main.go", "go", true},
+		{"Markdown", "README.md", Markdown, true},
+		{"Makefile", "Makefile", "makefile", true},
+		{"Unknown", "notes.xyz", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ForFilename(tt.filename)
+
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("want %q, %t; got %q, %t", tt.want, tt.wantOK, got, ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+// Package markdown renders user supplied Markdown to sanitized HTML
+package markdown
+
+import (
+	"bytes"
+	"html/template"
+	"regexp"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/util"
+	"robert-tu.net/snippetbox/pkg/highlight"
+)
+
+// md converts GitHub flavoured Markdown (tables, task lists, strikethrough, autolinks)
+// raw HTML in the source is dropped by goldmark's default safe mode
+var md = goldmark.New(
+	goldmark.WithExtensions(extension.GFM),
+	goldmark.WithRendererOptions(
+		renderer.WithNodeRenderers(util.Prioritized(&codeBlockRenderer{}, 100)),
+	),
+)
+
+// policy is an allowlist of the elements and attributes the renderer produces
+var policy = newPolicy()
+
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	// highlighted code blocks
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^[a-z0-9]+$`)).OnElements("span")
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^chroma$`)).OnElements("pre")
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[a-zA-Z0-9+#_-]+$`)).OnElements("code")
+	// task list checkboxes
+	p.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	p.AllowAttrs("checked", "disabled").Matching(regexp.MustCompile(`^$`)).OnElements("input")
+	return p
+}
+
+// Render converts Markdown source to HTML that is safe to embed in a page
+func Render(source string) (template.HTML, error) {
+	var buf bytes.Buffer
+	if err := md.Convert([]byte(source), &buf); err != nil {
+		return "", err
+	}
+	return template.HTML(policy.SanitizeBytes(buf.Bytes())), nil
+}
+
+// codeBlockRenderer highlights fenced code blocks with the highlight package
+type codeBlockRenderer struct{}
+
+func (r *codeBlockRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindFencedCodeBlock, r.renderFencedCodeBlock)
+}
+
+func (r *codeBlockRenderer) renderFencedCodeBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	n := node.(*ast.FencedCodeBlock)
+
+	var code bytes.Buffer
+	lines := n.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		segment := lines.At(i)
+		code.Write(segment.Value(source))
+	}
+
+	language := highlight.Plaintext
+	if l := n.Language(source); l != nil {
+		language = string(l)
+	}
+	highlighted, err := highlight.Lines(code.String(), language)
+	if err != nil {
+		return ast.WalkStop, err
+	}
+
+	w.WriteString(`<pre class="chroma"><code>`)
+	for i, line := range highlighted {
+		if i > 0 {
+			w.WriteByte('\n')
+		}
+		w.WriteString(string(line.HTML))
+	}
+	w.WriteString("</code></pre>\n")
+	return ast.WalkSkipChildren, nil
+}
@@ -0,0 +1,72 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name      string
+		source    string
+		want      []string
+		wantNotIn []string
+	}{
+		{
+			name:   "Table",
+			source: "| a | b |\n|---|---|\n| 1 | 2 |\n",
+			want:   []string{"<table>", "<th>a</th>", "<td>2</td>"},
+		},
+		{
+			name:   "Task list",
+			source: "- [x] done\n- [ ] todo\n",
+			want:   []string{`<input checked="" disabled="" type="checkbox"`, `<input disabled="" type="checkbox"`},
+		},
+		{
+			name:   "Fenced code",
+			source: "```go\npackage main\n```\n",
+			want:   []string{`<pre class="chroma"><code><span class="kn">package</span>`},
+		},
+		{
+			name:      "Script tag",
+			source:    "hello <script>alert(1)</script>",
+			want:      []string{"hello"},
+			wantNotIn: []string{"<script", "alert(1)</script>"},
+		},
+		{
+			name:      "Event handler",
+			source:    `<img src="x" onerror="alert(1)">`,
+			wantNotIn: []string{"onerror"},
+		},
+		{
+			name:      "JavaScript link",
+			source:    "[click](javascript:alert(1))",
+			wantNotIn: []string{"javascript:"},
+		},
+		{
+			name:      "Class injection",
+			source:    "```go onclick\nx\n```\n",
+			wantNotIn: []string{"onclick"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			html, err := Render(tt.source)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, want := range tt.want {
+				if !strings.Contains(string(html), want) {
+					t.Errorf("want %q to contain %q", html, want)
+				}
+			}
+			for _, bad := range tt.wantNotIn {
+				if strings.Contains(string(html), bad) {
+					t.Errorf("want %q not to contain %q", html, bad)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package mock
+
+import (
+	"time"
+
+	"robert-tu.net/snippetbox/pkg/models"
+)
+
+var mockImage = &models.Attachment{
+	ID:          1,
+	SnippetID:   1,
+	Name:        "frog.png",
+	ContentType: "image/png",
+	Size:        8,
+	Key:         "attachments/mock-frog",
+	Created:     time.Now(),
+}
+
+var mockPage = &models.Attachment{
+	ID:          2,
+	SnippetID:   1,
+	Name:        "page.html",
+	ContentType: "text/html; charset=utf-8",
+	Size:        25,
+	Key:         "attachments/mock-page",
+	Created:     time.Now(),
+}
+
+type AttachmentModel struct{}
+
+func (m *AttachmentModel) Insert(a *models.Attachment) (int, error) {
+	return 3, nil
+}
+
+func (m *AttachmentModel) Get(snippetID, id int) (*models.Attachment, error) {
+	for _, a := range []*models.Attachment{mockImage, mockPage} {
+		if a.SnippetID == snippetID && a.ID == id {
+			return a, nil
+		}
+	}
+	return nil, models.ErrNoRecord
+}
+
+func (m *AttachmentModel) ForSnippet(snippetID int) ([]*models.Attachment, error) {
+	switch snippetID {
+	case 1:
+		return []*models.Attachment{mockImage, mockPage}, nil
+	default:
+		return []*models.Attachment{}, nil
+	}
+}
+
+func (m *AttachmentModel) Delete(id int) error {
+	return nil
+}
+
+func (m *AttachmentModel) Orphans(limit int) ([]*models.Attachment, error) {
+	return []*models.Attachment{}, nil
+}
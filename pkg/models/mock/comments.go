@@ -0,0 +1,76 @@
+package mock
+
+import (
+	"time"
+
+	"robert-tu.net/snippetbox/pkg/models"
+)
+
+var mockComments = []*models.Comment{
+	{
+		ID:        1,
+		SnippetID: 1,
+		UserID:    2,
+		Author:    "Bob",
+		Content:   "Should the frog be in the title?",
+		Created:   time.Now(),
+	},
+	{
+		ID:        2,
+		SnippetID: 1,
+		ParentID:  1,
+		UserID:    1,
+		Author:    "Alice",
+		Content:   "It's in the second file.",
+		Created:   time.Now(),
+	},
+	{
+		ID:        3,
+		SnippetID: 7,
+		UserID:    2,
+		Author:    "Bob",
+		Content:   "Reminder to self",
+		Created:   time.Now(),
+	},
+	{
+		ID:        4,
+		SnippetID: 11,
+		UserID:    1,
+		Author:    "Alice",
+		Content:   "Lovely",
+		Created:   time.Now(),
+	},
+}
+
+type CommentModel struct{}
+
+func (m *CommentModel) Insert(snippetID, parentID, userID int, content string) (int, error) {
+	return 4, nil
+}
+
+func (m *CommentModel) Get(id int) (*models.Comment, error) {
+	for _, c := range mockComments {
+		if c.ID == id {
+			return c, nil
+		}
+	}
+	return nil, models.ErrNoRecord
+}
+
+func (m *CommentModel) ForSnippet(snippetID int) ([]*models.Comment, error) {
+	comments := []*models.Comment{}
+	for _, c := range mockComments {
+		if c.SnippetID == snippetID {
+			comments = append(comments, c)
+		}
+	}
+	return comments, nil
+}
+
+func (m *CommentModel) Update(id int, content string) error {
+	return nil
+}
+
+func (m *CommentModel) Delete(id int) error {
+	return nil
+}
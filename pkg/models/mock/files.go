@@ -0,0 +1,22 @@
+package mock
+
+import (
+	"robert-tu.net/snippetbox/pkg/models"
+)
+
+var mockFile = &models.File{
+	Name:     "frog.go",
+	Language: "go",
+	Content:  "package frog\n\nfunc Jump() {}\n",
+}
+
+type FileModel struct{}
+
+func (m *FileModel) ForSnippet(snippetID int) ([]*models.File, error) {
+	switch snippetID {
+	case 1:
+		return []*models.File{mockFile}, nil
+	default:
+		return []*models.File{}, nil
+	}
+}
@@ -1,22 +1,181 @@
 package mock
 
 import (
+	"strings"
 	"time"
 
 	"robert-tu.net/snippetbox/pkg/models"
 )
 
 var mockSnippet = &models.Snippet{
-	ID:      1,
-	Title:   "An old silent pond",
+	ID:         1,
+	Title:      "An old silent pond",
+	Content:    "...",
+	Created:    time.Now(),
+	Expires:    time.Now(),
+	Language:   "plaintext",
+	Filename:   "pond.txt",
+	UserID:     1,
+	Owner:      "Alice",
+	Version:    1,
+	Visibility: models.Public,
+	Slug:       "c2lsZW50LXBvbmQtc2x1Zw",
+	Stars:      3,
+}
+
+var mockMarkdownSnippet = &models.Snippet{
+	ID:         4,
+	Title:      "Notes",
+	Content:    "# Notes\n\n- [x] write haiku\n\n<script>alert(1)</script>\n",
+	Created:    time.Now(),
+	Expires:    time.Now(),
+	Language:   "markdown",
+	UserID:     1,
+	Owner:      "Alice",
+	Version:    1,
+	Visibility: models.Public,
+	Slug:       "bWFya2Rvd24tbm90ZXMtcw",
+}
+
+var mockUnlistedSnippet = &models.Snippet{
+	ID:         5,
+	Title:      "First autumn morning",
+	Content:    "...",
+	Created:    time.Now(),
+	Expires:    time.Now(),
+	Language:   "plaintext",
+	UserID:     1,
+	Owner:      "Alice",
+	Version:    1,
+	Visibility: models.Unlisted,
+	Slug:       "dW5saXN0ZWQtc25pcHBldA",
+}
+
+var mockPrivateSnippet = &models.Snippet{
+	ID:         6,
+	Title:      "A world of dew",
+	Content:    "...",
+	Created:    time.Now(),
+	Expires:    time.Now(),
+	Language:   "plaintext",
+	UserID:     1,
+	Owner:      "Alice",
+	Version:    1,
+	Visibility: models.Private,
+	Slug:       "cHJpdmF0ZS1zbmlwcGV0LQ",
+}
+
+var mockProtectedSnippet = &models.Snippet{
+	ID:         7,
+	Title:      "Temple bell",
+	Content:    "The temple bell stops",
+	Created:    time.Now(),
+	Expires:    time.Now(),
+	Language:   "plaintext",
+	UserID:     2,
+	Owner:      "Bob",
+	Version:    1,
+	Visibility: models.Public,
+	Slug:       "dGVtcGxlLWJlbGwtc3RvcA",
+	Protected:  true,
+}
+
+var mockBurnSnippet = &models.Snippet{
+	ID:         8,
+	Title:      "Lightning flash",
+	Content:    "What I thought were faces",
+	Created:    time.Now(),
+	Expires:    time.Now(),
+	Language:   "plaintext",
+	UserID:     2,
+	Owner:      "Bob",
+	Version:    1,
+	Visibility: models.Public,
+	Slug:       "bGlnaHRuaW5nLWZsYXNoLQ",
+	ViewLimit:  true,
+	ViewsLeft:  1,
+}
+
+var mockForkSnippet = &models.Snippet{
+	ID:         9,
+	Title:      "An old silent pond (remix)",
+	Content:    "...\nA frog jumps in",
+	Created:    time.Now(),
+	Expires:    time.Now(),
+	Language:   "plaintext",
+	UserID:     2,
+	Owner:      "Bob",
+	Version:    1,
+	Visibility: models.Public,
+	Slug:       "Zm9yay1vZi1zaWxlbnQtcA",
+	ParentID:   1,
+}
+
+var mockLargeSnippet = &models.Snippet{
+	ID:         10,
+	Title:      "A thousand frogs",
+	Content:    strings.Repeat("A frog jumps in\n", 6000),
+	Created:    time.Now(),
+	Expires:    time.Now(),
+	Language:   "plaintext",
+	UserID:     1,
+	Owner:      "Alice",
+	Version:    1,
+	Visibility: models.Public,
+	Slug:       "bGFyZ2Utc25pcHBldC10aA",
+}
+
+var mockHiddenSnippet = &models.Snippet{
+	ID:         11,
+	Title:      "Winter solitude",
+	Content:    "...",
+	Created:    time.Now(),
+	Expires:    time.Now(),
+	Language:   "plaintext",
+	UserID:     2,
+	Owner:      "Bob",
+	Version:    1,
+	Visibility: models.Private,
+	Slug:       "d2ludGVyLXNvbGl0dWRlLQ",
+}
+
+var mockDeletedSnippet = &models.Snippet{
+	ID:      3,
+	Title:   "Over the wintry forest",
 	Content: "...",
 	Created: time.Now(),
 	Expires: time.Now(),
+	UserID:  1,
+	Owner:   "Alice",
+	Version: 1,
+	Deleted: time.Now(),
+}
+
+var mockRevision = &models.Revision{
+	ID:        1,
+	SnippetID: 1,
+	Version:   1,
+	Title:     "An old silent pond",
+	Content:   "...",
+	UserID:    1,
+	Author:    "Alice",
+	Created:   time.Now(),
+}
+
+var mockRevision2 = &models.Revision{
+	ID:        2,
+	SnippetID: 1,
+	Version:   2,
+	Title:     "An old silent pond",
+	Content:   "...\nA frog jumps into the pond",
+	UserID:    1,
+	Author:    "Alice",
+	Created:   time.Now(),
 }
 
 type SnippetModel struct{}
 
-func (m *SnippetModel) Insert(title, content, expires string) (int, error) {
+func (m *SnippetModel) Insert(s *models.Snippet, passphrase string) (int, error) {
 	return 2, nil
 }
 
@@ -24,6 +183,92 @@ func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
 	switch id {
 	case 1:
 		return mockSnippet, nil
+	case 4:
+		return mockMarkdownSnippet, nil
+	case 5:
+		return mockUnlistedSnippet, nil
+	case 6:
+		return mockPrivateSnippet, nil
+	case 7:
+		return mockProtectedSnippet, nil
+	case 8:
+		return mockBurnSnippet, nil
+	case 9:
+		return mockForkSnippet, nil
+	case 10:
+		return mockLargeSnippet, nil
+	case 11:
+		return mockHiddenSnippet, nil
+	default:
+		return nil, models.ErrNoRecord
+	}
+}
+
+func (m *SnippetModel) Read(id int) (*models.Snippet, error) {
+	s, err := m.Get(id)
+	if err != nil || !s.ViewLimit {
+		return s, err
+	}
+	// the last view
+	read := *s
+	read.ViewsLeft = 0
+	return &read, nil
+}
+
+func (m *SnippetModel) GetBySlug(slug string) (*models.Snippet, error) {
+	for _, s := range []*models.Snippet{mockSnippet, mockMarkdownSnippet, mockUnlistedSnippet, mockPrivateSnippet, mockProtectedSnippet, mockBurnSnippet, mockForkSnippet, mockLargeSnippet, mockHiddenSnippet} {
+		if s.Slug == slug {
+			return s, nil
+		}
+	}
+	return nil, models.ErrNoRecord
+}
+
+func (m *SnippetModel) Update(id, userID, version int, title, content, language, visibility string, tags []string) error {
+	switch {
+	case id != 1:
+		return models.ErrNoRecord
+	case version != mockSnippet.Version:
+		return models.ErrEditConflict
+	default:
+		return nil
+	}
+}
+
+func (m *SnippetModel) SetPassphrase(id, userID int, passphrase string) error {
+	return nil
+}
+
+func (m *SnippetModel) SetExpires(id, userID int, expires time.Time) error {
+	return nil
+}
+
+func (m *SnippetModel) Unlock(id int, passphrase string) error {
+	switch {
+	case id == 7 && passphrase == "open sesame":
+		return nil
+	case id == 7:
+		return models.ErrInvalidCredentials
+	default:
+		return nil
+	}
+}
+
+func (m *SnippetModel) Revisions(id int) ([]*models.Revision, error) {
+	switch id {
+	case 1:
+		return []*models.Revision{mockRevision}, nil
+	default:
+		return []*models.Revision{}, nil
+	}
+}
+
+func (m *SnippetModel) Revision(id, version int) (*models.Revision, error) {
+	switch {
+	case id == 1 && version == 1:
+		return mockRevision, nil
+	case id == 1 && version == 2:
+		return mockRevision2, nil
 	default:
 		return nil, models.ErrNoRecord
 	}
@@ -32,3 +277,93 @@ func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
 func (m *SnippetModel) Latest() ([]*models.Snippet, error) {
 	return []*models.Snippet{mockSnippet}, nil
 }
+
+func (m *SnippetModel) Recent(tag string, userID, limit int) ([]*models.Snippet, error) {
+	if (tag == "" || tag == "haiku" || tag == "poetry") && (userID == 0 || userID == 1) {
+		return []*models.Snippet{mockSnippet}, nil
+	}
+	return []*models.Snippet{}, nil
+}
+
+func (m *SnippetModel) Page(before, after *models.Cursor, limit int) (*models.Page, error) {
+	// a single page, anything past a cursor is empty
+	if before != nil || after != nil {
+		return &models.Page{Snippets: []*models.Snippet{}}, nil
+	}
+	return &models.Page{
+		Snippets: []*models.Snippet{mockSnippet},
+		Older:    models.CursorFor(mockSnippet),
+	}, nil
+}
+
+func (m *SnippetModel) Search(query string, offset, limit int) ([]*models.Snippet, error) {
+	var snippets []*models.Snippet
+	if offset == 0 {
+		for _, s := range []*models.Snippet{mockSnippet, mockBurnSnippet} {
+			if strings.Contains(strings.ToLower(s.Title), strings.ToLower(query)) {
+				snippets = append(snippets, s)
+			}
+		}
+	}
+	return snippets, nil
+}
+
+func (m *SnippetModel) ByTag(tag string, offset, limit int) ([]*models.Snippet, error) {
+	if offset == 0 && (tag == "haiku" || tag == "poetry") {
+		return []*models.Snippet{mockSnippet}, nil
+	}
+	return []*models.Snippet{}, nil
+}
+
+func (m *SnippetModel) Trending(since time.Time, limit int) ([]*models.Snippet, error) {
+	return []*models.Snippet{mockSnippet}, nil
+}
+
+func (m *SnippetModel) Forks(id int) ([]*models.Snippet, error) {
+	switch id {
+	case 1:
+		return []*models.Snippet{mockForkSnippet}, nil
+	default:
+		return []*models.Snippet{}, nil
+	}
+}
+
+func (m *SnippetModel) ByUser(userID int) ([]*models.Snippet, error) {
+	switch userID {
+	case 1:
+		return []*models.Snippet{mockSnippet, mockUnlistedSnippet, mockPrivateSnippet}, nil
+	default:
+		return []*models.Snippet{}, nil
+	}
+}
+
+func (m *SnippetModel) Delete(id, userID int) error {
+	if id == 1 && userID == 1 {
+		return nil
+	}
+	return models.ErrNoRecord
+}
+
+func (m *SnippetModel) Restore(id, userID int) error {
+	if id == 3 && userID == 1 {
+		return nil
+	}
+	return models.ErrNoRecord
+}
+
+func (m *SnippetModel) Trash(userID int) ([]*models.Snippet, error) {
+	switch userID {
+	case 1:
+		return []*models.Snippet{mockDeletedSnippet}, nil
+	default:
+		return []*models.Snippet{}, nil
+	}
+}
+
+func (m *SnippetModel) PurgeDeleted(limit int) (int, error) {
+	return 0, nil
+}
+
+func (m *SnippetModel) PurgeExpired(limit int) (int, error) {
+	return 0, nil
+}
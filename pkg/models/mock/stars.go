@@ -0,0 +1,33 @@
+package mock
+
+import (
+	"time"
+
+	"robert-tu.net/snippetbox/pkg/models"
+)
+
+type StarModel struct{}
+
+func (m *StarModel) Star(userID, snippetID int, title string) error {
+	return nil
+}
+
+func (m *StarModel) Unstar(userID, snippetID int) error {
+	return nil
+}
+
+func (m *StarModel) Starred(userID, snippetID int) (bool, error) {
+	return userID == 1 && snippetID == 1, nil
+}
+
+func (m *StarModel) ByUser(userID int) ([]*models.Star, error) {
+	switch userID {
+	case 1:
+		return []*models.Star{
+			{SnippetID: 1, Title: "An old silent pond", Created: time.Now(), Snippet: mockSnippet},
+			{SnippetID: 2, Title: "A vanished haiku", Created: time.Now()},
+		}, nil
+	default:
+		return []*models.Star{}, nil
+	}
+}
@@ -0,0 +1,23 @@
+package mock
+
+import (
+	"robert-tu.net/snippetbox/pkg/models"
+)
+
+type TagModel struct{}
+
+func (m *TagModel) ForSnippet(snippetID int) ([]string, error) {
+	switch snippetID {
+	case 1:
+		return []string{"haiku", "poetry"}, nil
+	default:
+		return []string{}, nil
+	}
+}
+
+func (m *TagModel) Cloud(limit int) ([]*models.Tag, error) {
+	return []*models.Tag{
+		{Name: "haiku", Count: 1},
+		{Name: "poetry", Count: 1},
+	}, nil
+}
@@ -0,0 +1,46 @@
+package mock
+
+import (
+	"time"
+
+	"robert-tu.net/snippetbox/pkg/models"
+)
+
+type ViewModel struct{}
+
+func (m *ViewModel) Add(batch *models.ViewBatch) error {
+	return nil
+}
+
+func (m *ViewModel) Daily(snippetID int, since time.Time) ([]*models.DailyViews, error) {
+	switch snippetID {
+	case 1:
+		today := time.Now().UTC().Truncate(24 * time.Hour)
+		return []*models.DailyViews{
+			{SnippetID: 1, Day: today.AddDate(0, 0, -1), Views: 12, Unique: 5},
+			{SnippetID: 1, Day: today, Views: 4, Unique: 3},
+		}, nil
+	default:
+		return []*models.DailyViews{}, nil
+	}
+}
+
+func (m *ViewModel) Totals(snippetID int) (int, int, error) {
+	switch snippetID {
+	case 1:
+		return 16, 8, nil
+	default:
+		return 0, 0, nil
+	}
+}
+
+func (m *ViewModel) Referrers(snippetID, limit int) ([]*models.Referrer, error) {
+	switch snippetID {
+	case 1:
+		return []*models.Referrer{
+			{SnippetID: 1, URL: "https://news.example.com/item", Views: 7},
+		}, nil
+	default:
+		return []*models.Referrer{}, nil
+	}
+}
@@ -1,7 +1,9 @@
 package models
 
 import (
+	"encoding/base64"
 	"errors"
+	"fmt"
 	"time"
 )
 
@@ -11,13 +13,187 @@ var ErrNoRecord = errors.New("models: no matching record found")
 var ErrInvalidCredentials = errors.New("models: invalid credentials")
 var ErrDuplicateEmail = errors.New("models: duplicate email")
 
+// returned when an edit is based on an outdated version of a snippet
+var ErrEditConflict = errors.New("models: edit conflict")
+
+// who can see a snippet
+const (
+	// listed everywhere and reachable by ID
+	Public = "public"
+	// only reachable through its slug
+	Unlisted = "unlisted"
+	// only visible to its owner
+	Private = "private"
+)
+
 // Snippet type
 type Snippet struct {
 	ID      int
 	Title   string
 	Content string
 	Created time.Time
+	// zero for snippets that never expire
 	Expires time.Time
+	// name of a language in highlight.Languages
+	Language string
+	// author of the snippet
+	UserID int
+	Owner  string
+	// incremented on every edit
+	Version int
+	// set when the snippet was moved to the trash
+	Deleted time.Time
+	Tags    []string
+	// one of Public, Unlisted or Private
+	Visibility string
+	// random identifier used in shareable links
+	Slug string
+	// set when a passphrase is needed to read the snippet
+	Protected bool
+	// name of the main file, may be empty
+	Filename string
+	// files added alongside the main content
+	Files []*File
+	// snippet this one was forked from, 0 if it wasn't
+	ParentID int
+	// set when the snippet is destroyed after a number of views
+	ViewLimit bool
+	ViewsLeft int
+	// number of users who starred the snippet
+	Stars int
+}
+
+// File type holds one additional named file of a snippet
+type File struct {
+	Name     string
+	Language string
+	Content  string
+}
+
+// Attachment type describes a binary file uploaded alongside a snippet
+// its content is kept in a blob store under Key
+type Attachment struct {
+	ID          int
+	SnippetID   int
+	Name        string
+	ContentType string
+	Size        int64
+	Key         string
+	Created     time.Time
+}
+
+// Comment type holds a remark on a snippet, replying to another comment when ParentID is set
+type Comment struct {
+	ID        int
+	SnippetID int
+	ParentID  int
+	UserID    int
+	Author    string
+	Content   string
+	Created   time.Time
+	// zero unless the comment was edited
+	Updated time.Time
+	// set for deleted comments kept as the root of their replies
+	Deleted bool
+}
+
+// Star type records a user starring a snippet
+type Star struct {
+	SnippetID int
+	// title when the snippet was starred, kept once it is gone
+	Title   string
+	Created time.Time
+	// nil when the snippet expired, was deleted or can no longer be seen by the user
+	Snippet *Snippet
+}
+
+// DailyViews type counts the views of a snippet on one day
+type DailyViews struct {
+	SnippetID int
+	// midnight UTC
+	Day    time.Time
+	Views  int
+	Unique int
+}
+
+// Visitor type identifies one visitor of a snippet on one day by an anonymous hash
+type Visitor struct {
+	SnippetID int
+	Day       time.Time
+	Hash      string
+}
+
+// Referrer type counts views arriving from another page
+type Referrer struct {
+	SnippetID int
+	URL       string
+	Views     int
+}
+
+// ViewBatch type holds views counted in memory until they are saved
+type ViewBatch struct {
+	Views     []*DailyViews
+	Visitors  []*Visitor
+	Referrers []*Referrer
+}
+
+// Tag type with the number of snippets using it
+type Tag struct {
+	Name  string
+	Count int
+}
+
+// Revision type holds one saved version of a snippet
+type Revision struct {
+	ID        int
+	SnippetID int
+	Version   int
+	Title     string
+	Content   string
+	UserID    int
+	Author    string
+	Created   time.Time
+}
+
+// Cursor marks a position in a listing of snippets ordered by Created and ID
+type Cursor struct {
+	Created time.Time
+	ID      int
+}
+
+// CursorFor returns the position of a snippet
+func CursorFor(s *Snippet) *Cursor {
+	return &Cursor{Created: s.Created, ID: s.ID}
+}
+
+// String encodes the cursor as an opaque URL-safe token
+func (c *Cursor) String() string {
+	raw := fmt.Sprintf("%d:%d", c.Created.Unix(), c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// ParseCursor decodes a token created by Cursor.String
+func ParseCursor(token string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, err
+	}
+	var created int64
+	c := &Cursor{}
+	_, err = fmt.Sscanf(string(raw), "%d:%d", &created, &c.ID)
+	if err != nil {
+		return nil, err
+	}
+	c.Created = time.Unix(created, 0).UTC()
+	return c, nil
+}
+
+// Page type holds one page of a snippet listing
+// Newer and Older are nil when there is nothing more in that direction
+type Page struct {
+	Snippets []*Snippet
+	Newer    *Cursor
+	Older    *Cursor
 }
 
 // User type
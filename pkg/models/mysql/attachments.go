@@ -0,0 +1,98 @@
+package mysql
+
+import (
+	"database/sql"
+	"errors"
+
+	"robert-tu.net/snippetbox/pkg/models"
+)
+
+// define AttachmentModel which wraps sql.DB
+type AttachmentModel struct {
+	DB *sql.DB
+}
+
+const attachmentColumns = `id, snippet_id, name, content_type, size, blob_key, created`
+
+// Insert records an attachment whose content is already in the blob store
+func (m *AttachmentModel) Insert(a *models.Attachment) (int, error) {
+	stmt := `INSERT INTO snippet_attachments (snippet_id, name, content_type, size, blob_key, created)
+			VALUES (?, ?, ?, ?, ?, UTC_TIMESTAMP())`
+
+	result, err := m.DB.Exec(stmt, a.SnippetID, a.Name, a.ContentType, a.Size, a.Key)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
+
+// Get returns an attachment of a snippet
+func (m *AttachmentModel) Get(snippetID, id int) (*models.Attachment, error) {
+	stmt := `SELECT ` + attachmentColumns + ` FROM snippet_attachments
+			WHERE snippet_id = ? AND id = ?`
+
+	a := &models.Attachment{}
+	err := m.DB.QueryRow(stmt, snippetID, id).Scan(&a.ID, &a.SnippetID, &a.Name, &a.ContentType, &a.Size, &a.Key, &a.Created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, err
+	}
+	return a, nil
+}
+
+// ForSnippet returns the attachments of a snippet, oldest first
+func (m *AttachmentModel) ForSnippet(snippetID int) ([]*models.Attachment, error) {
+	stmt := `SELECT ` + attachmentColumns + ` FROM snippet_attachments
+			WHERE snippet_id = ?
+			ORDER BY id`
+
+	return m.query(stmt, snippetID)
+}
+
+// Delete removes the record of an attachment
+// the caller is responsible for its blob
+func (m *AttachmentModel) Delete(id int) error {
+	_, err := m.DB.Exec(`DELETE FROM snippet_attachments WHERE id = ?`, id)
+	return err
+}
+
+// Orphans returns up to limit attachments whose snippet has been purged
+func (m *AttachmentModel) Orphans(limit int) ([]*models.Attachment, error) {
+	stmt := `SELECT a.id, a.snippet_id, a.name, a.content_type, a.size, a.blob_key, a.created
+			FROM snippet_attachments a LEFT JOIN snippets s ON s.id = a.snippet_id
+			WHERE s.id IS NULL
+			ORDER BY a.id LIMIT ?`
+
+	return m.query(stmt, limit)
+}
+
+// query returns the attachments selected by stmt
+func (m *AttachmentModel) query(stmt string, args ...interface{}) ([]*models.Attachment, error) {
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attachments := []*models.Attachment{}
+	for rows.Next() {
+		a := &models.Attachment{}
+		err = rows.Scan(&a.ID, &a.SnippetID, &a.Name, &a.ContentType, &a.Size, &a.Key, &a.Created)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, a)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return attachments, nil
+}
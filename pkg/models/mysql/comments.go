@@ -0,0 +1,168 @@
+package mysql
+
+import (
+	"database/sql"
+	"errors"
+
+	"robert-tu.net/snippetbox/pkg/models"
+)
+
+// define CommentModel which wraps sql.DB
+type CommentModel struct {
+	DB *sql.DB
+}
+
+const commentColumns = `c.id, c.snippet_id, COALESCE(c.parent_id, 0), c.user_id, u.name, c.content, c.created, c.updated, c.deleted`
+
+// scanComment copies commentColumns into a new Comment struct
+func scanComment(row scanner) (*models.Comment, error) {
+	c := &models.Comment{}
+	// NULL for comments that were never edited
+	var updated sql.NullTime
+	err := row.Scan(&c.ID, &c.SnippetID, &c.ParentID, &c.UserID, &c.Author, &c.Content, &c.Created, &updated, &c.Deleted)
+	if err != nil {
+		return nil, err
+	}
+	c.Updated = updated.Time
+	return c, nil
+}
+
+// Insert adds a comment to a snippet, parentID is 0 for a new thread
+func (m *CommentModel) Insert(snippetID, parentID, userID int, content string) (int, error) {
+	var parent interface{}
+	if parentID != 0 {
+		parent = parentID
+	}
+
+	stmt := `INSERT INTO comments (snippet_id, parent_id, user_id, content, created)
+			VALUES (?, ?, ?, ?, UTC_TIMESTAMP())`
+
+	result, err := m.DB.Exec(stmt, snippetID, parent, userID, content)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
+
+// Get returns a comment by ID
+func (m *CommentModel) Get(id int) (*models.Comment, error) {
+	stmt := `SELECT ` + commentColumns + `
+			FROM comments c INNER JOIN users u ON u.id = c.user_id
+			WHERE c.id = ?`
+
+	c, err := scanComment(m.DB.QueryRow(stmt, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, err
+	}
+	return c, nil
+}
+
+// ForSnippet returns every comment of a snippet, oldest first
+func (m *CommentModel) ForSnippet(snippetID int) ([]*models.Comment, error) {
+	stmt := `SELECT ` + commentColumns + `
+			FROM comments c INNER JOIN users u ON u.id = c.user_id
+			WHERE c.snippet_id = ?
+			ORDER BY c.created, c.id`
+
+	rows, err := m.DB.Query(stmt, snippetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []*models.Comment{}
+	for rows.Next() {
+		c, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, c)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return comments, nil
+}
+
+// Update replaces the content of a comment
+// returns models.ErrNoRecord if it doesn't exist or was deleted
+func (m *CommentModel) Update(id int, content string) error {
+	stmt := `UPDATE comments SET content = ?, updated = UTC_TIMESTAMP()
+			WHERE id = ? AND NOT deleted`
+
+	result, err := m.DB.Exec(stmt, content, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return models.ErrNoRecord
+	}
+	return nil
+}
+
+// Delete removes a comment
+// a comment with replies is blanked instead so the thread stays readable
+func (m *CommentModel) Delete(id int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var replies bool
+	err = tx.QueryRow(`SELECT EXISTS(SELECT true FROM comments WHERE parent_id = ?)`, id).Scan(&replies)
+	if err != nil {
+		return err
+	}
+
+	if replies {
+		_, err = tx.Exec(`UPDATE comments SET content = '', deleted = TRUE WHERE id = ?`, id)
+		if err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+
+	// a blanked parent left without replies goes along with its last reply
+	for id != 0 {
+		var parent sql.NullInt64
+		err = tx.QueryRow(`SELECT parent_id FROM comments WHERE id = ?`, id).Scan(&parent)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return models.ErrNoRecord
+			}
+			return err
+		}
+
+		_, err = tx.Exec(`DELETE FROM comments WHERE id = ?`, id)
+		if err != nil {
+			return err
+		}
+
+		id = 0
+		if parent.Valid {
+			stmt := `SELECT c.id FROM comments c
+					WHERE c.id = ? AND c.deleted AND NOT EXISTS(SELECT true FROM comments r WHERE r.parent_id = c.id)`
+			err = tx.QueryRow(stmt, parent.Int64).Scan(&id)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
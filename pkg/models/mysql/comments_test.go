@@ -0,0 +1,57 @@
+package mysql
+
+import (
+	"errors"
+	"testing"
+
+	"robert-tu.net/snippetbox/pkg/models"
+)
+
+func TestCommentModelDelete(t *testing.T) {
+	// skip test if -short flag
+	if testing.Short() {
+		t.Skip("mysql: skipping integration test")
+	}
+
+	// initialize connection pool
+	db, teardown := newTestDB(t)
+	defer teardown()
+
+	m := CommentModel{db}
+
+	// a thread of three comments, each replying to the one before
+	var ids []int
+	parent := 0
+	for _, content := range []string{"Old pond", "Frog jumps", "Splash"} {
+		id, err := m.Insert(2, parent, 1, content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+		parent = id
+	}
+
+	// comments with replies are blanked
+	for _, id := range ids[:2] {
+		if err := m.Delete(id); err != nil {
+			t.Fatal(err)
+		}
+		c, err := m.Get(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !c.Deleted || c.Content != "" {
+			t.Errorf("want comment %d blanked; got %+v", id, c)
+		}
+	}
+
+	// deleting the last reply removes the blanked comments above it
+	if err := m.Delete(ids[2]); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range ids {
+		if _, err := m.Get(id); !errors.Is(err, models.ErrNoRecord) {
+			t.Errorf("want comment %d removed; got %v", id, err)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package mysql
+
+import (
+	"database/sql"
+
+	"robert-tu.net/snippetbox/pkg/models"
+)
+
+// define FileModel which wraps sql.DB
+type FileModel struct {
+	DB *sql.DB
+}
+
+// insertFiles stores the additional files of a new snippet within tx, keeping their order
+func insertFiles(tx *sql.Tx, snippetID int, files []*models.File) error {
+	stmt := `INSERT INTO snippet_files (snippet_id, position, name, language, content)
+			VALUES (?, ?, ?, ?, ?)`
+	for i, f := range files {
+		_, err := tx.Exec(stmt, snippetID, i, f.Name, f.Language, f.Content)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ForSnippet returns the additional files of a snippet in order
+func (m *FileModel) ForSnippet(snippetID int) ([]*models.File, error) {
+	stmt := `SELECT name, language, content FROM snippet_files
+			WHERE snippet_id = ?
+			ORDER BY position`
+
+	rows, err := m.DB.Query(stmt, snippetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	files := []*models.File{}
+	for rows.Next() {
+		f := &models.File{}
+		if err = rows.Scan(&f.Name, &f.Language, &f.Content); err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
@@ -0,0 +1,60 @@
+package mysql
+
+import (
+	"reflect"
+	"testing"
+
+	"robert-tu.net/snippetbox/pkg/models"
+)
+
+func TestSnippetModelInsertFiles(t *testing.T) {
+	// skip test if -short flag
+	if testing.Short() {
+		t.Skip("mysql: skipping integration test")
+	}
+
+	// initialize connection pool
+	db, teardown := newTestDB(t)
+	defer teardown()
+
+	m := SnippetModel{db}
+	files := FileModel{db}
+
+	s := &models.Snippet{UserID: 1, Title: "Frog", Content: "...", Language: "plaintext", Visibility: models.Public, Files: []*models.File{
+		{Name: "frog.go", Language: "go", Content: "package frog\n"},
+		{Name: "go.mod", Language: "plaintext", Content: "module frog\n"},
+	}}
+	id, err := m.Insert(s, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := files.ForSnippet(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, s.Files) {
+		t.Errorf("want files %v; got %v", s.Files, got)
+	}
+
+	// a failing file write leaves no part of the snippet behind
+	s.Title = "Half a frog"
+	s.Tags = []string{"frogs"}
+	s.Files = append(s.Files, &models.File{Name: "frog.go", Language: "go", Content: "package frog\n"})
+	if _, err = m.Insert(s, ""); err == nil {
+		t.Fatal("want error for duplicate file names")
+	}
+
+	for _, stmt := range []string{
+		`SELECT COUNT(*) FROM snippets WHERE title = 'Half a frog'`,
+		`SELECT COUNT(*) FROM snippet_revisions WHERE title = 'Half a frog'`,
+		`SELECT COUNT(*) FROM snippet_tags`,
+	} {
+		var n int
+		if err = db.QueryRow(stmt).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		if n != 0 {
+			t.Errorf("want no rows from %q; got %d", stmt, n)
+		}
+	}
+}
@@ -1,23 +1,115 @@
 package mysql
 
 import (
+	"crypto/rand"
 	"database/sql"
+	"encoding/base64"
 	"errors"
+	"time"
 
+	"golang.org/x/crypto/bcrypt"
 	"robert-tu.net/snippetbox/pkg/models"
 )
 
+// number of days a deleted snippet stays in the trash before it is purged
+const trashDays = 30
+
+// columns selected for a snippet, joined with the owner's name
+const snippetColumns = `s.id, s.title, s.content, s.created, s.expires, s.user_id, u.name, s.version, s.language, s.visibility, s.slug, s.hashed_passphrase IS NOT NULL,
+	s.views_left IS NOT NULL, COALESCE(s.views_left, 0), COALESCE(s.parent_id, 0), s.filename,
+	(SELECT COUNT(*) FROM stars WHERE stars.snippet_id = s.id)`
+
+// condition matching snippets that haven't expired, been deleted or used up their views
+const available = `(s.expires IS NULL OR s.expires > UTC_TIMESTAMP()) AND s.deleted_at IS NULL AND (s.views_left IS NULL OR s.views_left > 0)`
+
 // define SnippetModel which wraps sql.DB
 type SnippetModel struct {
 	DB *sql.DB
 }
 
+// scanner is satisfied by both *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanSnippet copies snippetColumns into a new Snippet struct
+// extra receives any columns selected after snippetColumns
+func scanSnippet(row scanner, extra ...interface{}) (*models.Snippet, error) {
+	s := &models.Snippet{}
+	// NULL for snippets that never expire
+	var expires sql.NullTime
+	dest := []interface{}{&s.ID, &s.Title, &s.Content, &s.Created, &expires, &s.UserID, &s.Owner, &s.Version, &s.Language, &s.Visibility, &s.Slug, &s.Protected, &s.ViewLimit, &s.ViewsLeft, &s.ParentID, &s.Filename, &s.Stars}
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return nil, err
+	}
+	s.Expires = expires.Time
+	return s, nil
+}
+
+// nullTime stores the zero time as NULL
+func nullTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t.UTC()
+}
+
+// newSlug returns 128 random bits encoded for use in a URL
+func newSlug() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashPassphrase returns the bcrypt hash of a passphrase, or NULL for an empty one
+func hashPassphrase(passphrase string) (interface{}, error) {
+	if passphrase == "" {
+		return nil, nil
+	}
+	hashed, err := bcrypt.GenerateFromPassword([]byte(passphrase), 12)
+	if err != nil {
+		return nil, err
+	}
+	return string(hashed), nil
+}
+
 // insert
-func (m *SnippetModel) Insert(title, content, expires string) (int, error) {
-	stmt := `INSERT INTO snippets (title, content, created, expires)
-    		VALUES(?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY))`
+// the snippet, its first revision, its tags and its files are written in one transaction
+// a zero Expires never expires, an empty passphrase leaves the snippet unprotected,
+// ViewsLeft is only stored when ViewLimit is set and ParentID when it isn't 0
+func (m *SnippetModel) Insert(s *models.Snippet, passphrase string) (int, error) {
+	slug, err := newSlug()
+	if err != nil {
+		return 0, err
+	}
 
-	result, err := m.DB.Exec(stmt, title, content, expires)
+	hashedPassphrase, err := hashPassphrase(passphrase)
+	if err != nil {
+		return 0, err
+	}
+
+	var viewsLeft, parentID interface{}
+	if s.ViewLimit {
+		viewsLeft = s.ViewsLeft
+	}
+	if s.ParentID != 0 {
+		parentID = s.ParentID
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	// rollback is a no-op once the transaction is committed
+	defer tx.Rollback()
+
+	stmt := `INSERT INTO snippets (user_id, title, content, language, filename, visibility, slug, hashed_passphrase, views_left, parent_id, created, expires)
+    		VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, UTC_TIMESTAMP(), ?)`
+
+	result, err := tx.Exec(stmt, s.UserID, s.Title, s.Content, s.Language, s.Filename, s.Visibility, slug, hashedPassphrase, viewsLeft, parentID, nullTime(s.Expires))
 	if err != nil {
 		return 0, err
 	}
@@ -27,22 +119,197 @@ func (m *SnippetModel) Insert(title, content, expires string) (int, error) {
 		return 0, err
 	}
 
-	return int(id), nil
+	err = insertRevision(tx, int(id), 1, s.UserID, s.Title, s.Content)
+	if err != nil {
+		return 0, err
+	}
+
+	err = setTags(tx, int(id), s.Tags)
+	if err != nil {
+		return 0, err
+	}
+
+	err = insertFiles(tx, int(id), s.Files)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), tx.Commit()
 
 }
 
+// Update saves a new version of a snippet and replaces its tags
+// returns models.ErrEditConflict if the snippet changed since version was read
+func (m *SnippetModel) Update(id, userID, version int, title, content, language, visibility string, tags []string) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// only matches if nobody else saved in the meantime
+	stmt := `UPDATE snippets SET title = ?, content = ?, language = ?, visibility = ?, version = version + 1
+			WHERE id = ? AND version = ? AND (expires IS NULL OR expires > UTC_TIMESTAMP()) AND deleted_at IS NULL AND (views_left IS NULL OR views_left > 0)`
+
+	result, err := tx.Exec(stmt, title, content, language, visibility, id, version)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		// distinguish a stale version from a missing snippet
+		var exists bool
+		stmt = `SELECT EXISTS(SELECT true FROM snippets WHERE id = ? AND (expires IS NULL OR expires > UTC_TIMESTAMP()) AND deleted_at IS NULL AND (views_left IS NULL OR views_left > 0))`
+		err = tx.QueryRow(stmt, id).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return models.ErrNoRecord
+		}
+		return models.ErrEditConflict
+	}
+
+	err = insertRevision(tx, id, version+1, userID, title, content)
+	if err != nil {
+		return err
+	}
+
+	err = setTags(tx, id, tags)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SetPassphrase protects a snippet owned by userID with a new passphrase
+// an empty passphrase removes the protection
+func (m *SnippetModel) SetPassphrase(id, userID int, passphrase string) error {
+	hashedPassphrase, err := hashPassphrase(passphrase)
+	if err != nil {
+		return err
+	}
+
+	stmt := `UPDATE snippets SET hashed_passphrase = ?
+			WHERE id = ? AND user_id = ? AND deleted_at IS NULL`
+
+	_, err = m.DB.Exec(stmt, hashedPassphrase, id, userID)
+	return err
+}
+
+// SetExpires changes when a snippet owned by userID expires, a zero time means never
+func (m *SnippetModel) SetExpires(id, userID int, expires time.Time) error {
+	stmt := `UPDATE snippets SET expires = ?
+			WHERE id = ? AND user_id = ? AND deleted_at IS NULL`
+
+	_, err := m.DB.Exec(stmt, nullTime(expires), id, userID)
+	return err
+}
+
+// Unlock checks a passphrase against the one protecting a snippet
+// returns models.ErrInvalidCredentials if it doesn't match
+func (m *SnippetModel) Unlock(id int, passphrase string) error {
+	var hashedPassphrase sql.NullString
+	stmt := `SELECT hashed_passphrase FROM snippets
+			WHERE id = ? AND (expires IS NULL OR expires > UTC_TIMESTAMP()) AND deleted_at IS NULL AND (views_left IS NULL OR views_left > 0)`
+
+	err := m.DB.QueryRow(stmt, id).Scan(&hashedPassphrase)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.ErrNoRecord
+		} else {
+			return err
+		}
+	}
+	// nothing to unlock
+	if !hashedPassphrase.Valid {
+		return nil
+	}
+
+	err = bcrypt.CompareHashAndPassword([]byte(hashedPassphrase.String), []byte(passphrase))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return models.ErrInvalidCredentials
+		} else {
+			return err
+		}
+	}
+	return nil
+}
+
+// insertRevision records a version of a snippet in snippet_revisions
+func insertRevision(tx *sql.Tx, snippetID, version, userID int, title, content string) error {
+	stmt := `INSERT INTO snippet_revisions (snippet_id, version, user_id, title, content, created)
+			VALUES(?, ?, ?, ?, ?, UTC_TIMESTAMP())`
+
+	_, err := tx.Exec(stmt, snippetID, version, userID, title, content)
+	return err
+}
+
 // get
 func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
-	stmt := `SELECT id, title, content, created, expires
-			FROM snippets
-			WHERE expires > UTC_TIMESTAMP() and id = ?`
+	stmt := `SELECT ` + snippetColumns + `
+			FROM snippets s INNER JOIN users u ON u.id = s.user_id
+			WHERE ` + available + ` AND s.id = ?`
 
 	// QueryRow() to return pointer of object
-	row := m.DB.QueryRow(stmt, id)
-	// pointer to new Snippet struct
-	s := &models.Snippet{}
+	s, err := scanSnippet(m.DB.QueryRow(stmt, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Read returns a snippet to show to a reader, using up one view if it is view limited
+// the row is locked until the view is counted so two readers can't both get the last one
+func (m *SnippetModel) Read(id int) (*models.Snippet, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	stmt := `SELECT ` + snippetColumns + `
+			FROM snippets s INNER JOIN users u ON u.id = s.user_id
+			WHERE ` + available + ` AND s.id = ?
+			FOR UPDATE`
+
+	s, err := scanSnippet(tx.QueryRow(stmt, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
 
-	err := row.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+	if s.ViewLimit {
+		_, err = tx.Exec(`UPDATE snippets SET views_left = views_left - 1 WHERE id = ?`, id)
+		if err != nil {
+			return nil, err
+		}
+		s.ViewsLeft--
+	}
+
+	return s, tx.Commit()
+}
+
+// GetBySlug returns the snippet with the given slug
+func (m *SnippetModel) GetBySlug(slug string) (*models.Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + `
+			FROM snippets s INNER JOIN users u ON u.id = s.user_id
+			WHERE ` + available + ` AND s.slug = ?`
+
+	s, err := scanSnippet(m.DB.QueryRow(stmt, slug))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, models.ErrNoRecord
@@ -55,10 +322,281 @@ func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
 
 // top 10
 func (m *SnippetModel) Latest() ([]*models.Snippet, error) {
-	stmt := `SELECT id, title, content, created, expires
-			FROM snippets
-			WHERE expires > UTC_TIMESTAMP() ORDER BY created DESC LIMIT 10`
-	rows, err := m.DB.Query(stmt)
+	return m.Recent("", 0, 10)
+}
+
+// Recent returns up to limit of the newest public snippets
+// only those tagged tag and owned by userID are returned when they are set
+func (m *SnippetModel) Recent(tag string, userID, limit int) ([]*models.Snippet, error) {
+	where := ""
+	args := []interface{}{}
+	if tag != "" {
+		where += ` AND s.id IN (SELECT st.snippet_id FROM snippet_tags st INNER JOIN tags t ON t.id = st.tag_id WHERE t.name = ?)`
+		args = append(args, tag)
+	}
+	if userID != 0 {
+		where += ` AND s.user_id = ?`
+		args = append(args, userID)
+	}
+
+	stmt := `SELECT ` + snippetColumns + `
+			FROM snippets s INNER JOIN users u ON u.id = s.user_id
+			WHERE ` + available + ` AND s.visibility = 'public'` + where + `
+			ORDER BY s.created DESC, s.id DESC LIMIT ?`
+	args = append(args, limit)
+	return m.query(stmt, args...)
+}
+
+// Page returns up to limit public snippets, newest first, using keyset pagination over (created, id)
+// the page starts after the before cursor or ends before the after cursor, pass nil for neither
+func (m *SnippetModel) Page(before, after *models.Cursor, limit int) (*models.Page, error) {
+	where := ""
+	order := "DESC"
+	args := []interface{}{}
+	switch {
+	case before != nil:
+		where = "AND (s.created < ? OR (s.created = ? AND s.id < ?))"
+		args = append(args, before.Created, before.Created, before.ID)
+	case after != nil:
+		// walk forwards from the cursor then flip the result
+		where = "AND (s.created > ? OR (s.created = ? AND s.id > ?))"
+		order = "ASC"
+		args = append(args, after.Created, after.Created, after.ID)
+	}
+
+	// fetch one extra row to find out if there is another page
+	stmt := `SELECT ` + snippetColumns + `
+			FROM snippets s INNER JOIN users u ON u.id = s.user_id
+			WHERE ` + available + ` AND s.visibility = 'public' ` + where + `
+			ORDER BY s.created ` + order + `, s.id ` + order + ` LIMIT ?`
+	args = append(args, limit+1)
+
+	snippets, err := m.query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	more := len(snippets) > limit
+	if more {
+		snippets = snippets[:limit]
+	}
+	if after != nil {
+		for l, r := 0, len(snippets)-1; l < r; l, r = l+1, r-1 {
+			snippets[l], snippets[r] = snippets[r], snippets[l]
+		}
+	}
+
+	p := &models.Page{Snippets: snippets}
+	if len(snippets) == 0 {
+		return p, nil
+	}
+	// a cursor means we came from the other direction, so there is a page there
+	if (after == nil && more) || after != nil {
+		p.Older = models.CursorFor(snippets[len(snippets)-1])
+	}
+	if (after != nil && more) || before != nil {
+		p.Newer = models.CursorFor(snippets[0])
+	}
+	return p, nil
+}
+
+// Search returns unexpired public snippets matching a full-text query, most relevant first
+// protected and view-limited snippets are left out so their content can't be probed
+func (m *SnippetModel) Search(query string, offset, limit int) ([]*models.Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + `
+			FROM snippets s INNER JOIN users u ON u.id = s.user_id
+			WHERE ` + available + ` AND s.visibility = 'public'
+			AND s.hashed_passphrase IS NULL AND s.views_left IS NULL
+			AND MATCH(s.title, s.content) AGAINST(? IN NATURAL LANGUAGE MODE)
+			ORDER BY MATCH(s.title, s.content) AGAINST(? IN NATURAL LANGUAGE MODE) DESC, s.id DESC
+			LIMIT ? OFFSET ?`
+	return m.query(stmt, query, query, limit, offset)
+}
+
+// ByTag returns unexpired public snippets with the given tag, newest first
+func (m *SnippetModel) ByTag(tag string, offset, limit int) ([]*models.Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + `
+			FROM snippets s INNER JOIN users u ON u.id = s.user_id
+			INNER JOIN snippet_tags st ON st.snippet_id = s.id
+			INNER JOIN tags t ON t.id = st.tag_id
+			WHERE ` + available + ` AND s.visibility = 'public' AND t.name = ?
+			ORDER BY s.created DESC, s.id DESC
+			LIMIT ? OFFSET ?`
+	return m.query(stmt, tag, limit, offset)
+}
+
+// Trending returns up to limit public snippets with the most stars given since the given time
+func (m *SnippetModel) Trending(since time.Time, limit int) ([]*models.Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + `
+			FROM snippets s INNER JOIN users u ON u.id = s.user_id
+			INNER JOIN (
+				SELECT snippet_id, COUNT(*) AS recent FROM stars
+				WHERE created > ?
+				GROUP BY snippet_id
+			) t ON t.snippet_id = s.id
+			WHERE ` + available + ` AND s.visibility = 'public'
+			ORDER BY t.recent DESC, s.created DESC, s.id DESC
+			LIMIT ?`
+	return m.query(stmt, since.UTC(), limit)
+}
+
+// Forks returns the unexpired public snippets forked from the given snippet, newest first
+func (m *SnippetModel) Forks(id int) ([]*models.Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + `
+			FROM snippets s INNER JOIN users u ON u.id = s.user_id
+			WHERE ` + available + ` AND s.visibility = 'public' AND s.parent_id = ?
+			ORDER BY s.created DESC, s.id DESC`
+	return m.query(stmt, id)
+}
+
+// ByUser returns every unexpired snippet created by the given user
+func (m *SnippetModel) ByUser(userID int) ([]*models.Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + `
+			FROM snippets s INNER JOIN users u ON u.id = s.user_id
+			WHERE ` + available + ` AND s.user_id = ?
+			ORDER BY s.created DESC`
+	return m.query(stmt, userID)
+}
+
+// Delete moves a snippet owned by userID to the trash
+func (m *SnippetModel) Delete(id, userID int) error {
+	stmt := `UPDATE snippets SET deleted_at = UTC_TIMESTAMP()
+			WHERE id = ? AND user_id = ? AND deleted_at IS NULL`
+	return m.execOne(stmt, id, userID)
+}
+
+// Restore takes a snippet owned by userID back out of the trash
+func (m *SnippetModel) Restore(id, userID int) error {
+	stmt := `UPDATE snippets SET deleted_at = NULL
+			WHERE id = ? AND user_id = ? AND deleted_at > DATE_SUB(UTC_TIMESTAMP(), INTERVAL ? DAY)`
+	return m.execOne(stmt, id, userID, trashDays)
+}
+
+// Trash returns the snippets deleted by userID that can still be restored
+func (m *SnippetModel) Trash(userID int) ([]*models.Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + `, s.deleted_at
+			FROM snippets s INNER JOIN users u ON u.id = s.user_id
+			WHERE s.user_id = ? AND s.deleted_at > DATE_SUB(UTC_TIMESTAMP(), INTERVAL ? DAY)
+			ORDER BY s.deleted_at DESC`
+
+	rows, err := m.DB.Query(stmt, userID, trashDays)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snippets := []*models.Snippet{}
+	for rows.Next() {
+		var deleted time.Time
+		s, err := scanSnippet(rows, &deleted)
+		if err != nil {
+			return nil, err
+		}
+		s.Deleted = deleted
+		snippets = append(snippets, s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return snippets, nil
+}
+
+// PurgeDeleted permanently removes up to limit snippets that have been in the trash too long
+// returns the number of snippets removed
+func (m *SnippetModel) PurgeDeleted(limit int) (int, error) {
+	stmt := `DELETE FROM snippets WHERE deleted_at <= DATE_SUB(UTC_TIMESTAMP(), INTERVAL ? DAY)
+			ORDER BY id LIMIT ?`
+	return m.execCount(stmt, trashDays, limit)
+}
+
+// PurgeExpired permanently removes up to limit snippets that expired or used up their views
+// returns the number of snippets removed
+func (m *SnippetModel) PurgeExpired(limit int) (int, error) {
+	stmt := `DELETE FROM snippets WHERE expires <= UTC_TIMESTAMP() OR views_left = 0
+			ORDER BY id LIMIT ?`
+	return m.execCount(stmt, limit)
+}
+
+// execCount runs a statement and returns the number of rows it changed
+func (m *SnippetModel) execCount(stmt string, args ...interface{}) (int, error) {
+	result, err := m.DB.Exec(stmt, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+// execOne runs a statement that must change exactly one row
+// returns models.ErrNoRecord if no row matched
+func (m *SnippetModel) execOne(stmt string, args ...interface{}) error {
+	result, err := m.DB.Exec(stmt, args...)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return models.ErrNoRecord
+	}
+	return nil
+}
+
+// Revisions returns the saved versions of a snippet, newest first
+func (m *SnippetModel) Revisions(id int) ([]*models.Revision, error) {
+	stmt := `SELECT r.id, r.snippet_id, r.version, r.title, r.content, r.user_id, u.name, r.created
+			FROM snippet_revisions r INNER JOIN users u ON u.id = r.user_id
+			WHERE r.snippet_id = ?
+			ORDER BY r.version DESC`
+
+	rows, err := m.DB.Query(stmt, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []*models.Revision{}
+	for rows.Next() {
+		rev := &models.Revision{}
+		err = rows.Scan(&rev.ID, &rev.SnippetID, &rev.Version, &rev.Title, &rev.Content, &rev.UserID, &rev.Author, &rev.Created)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, rev)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+// Revision returns a single saved version of a snippet
+func (m *SnippetModel) Revision(id, version int) (*models.Revision, error) {
+	stmt := `SELECT r.id, r.snippet_id, r.version, r.title, r.content, r.user_id, u.name, r.created
+			FROM snippet_revisions r INNER JOIN users u ON u.id = r.user_id
+			WHERE r.snippet_id = ? AND r.version = ?`
+
+	rev := &models.Revision{}
+	err := m.DB.QueryRow(stmt, id, version).Scan(&rev.ID, &rev.SnippetID, &rev.Version, &rev.Title, &rev.Content, &rev.UserID, &rev.Author, &rev.Created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+	return rev, nil
+}
+
+// query runs a statement selecting snippetColumns and collects the rows
+func (m *SnippetModel) query(stmt string, args ...interface{}) ([]*models.Snippet, error) {
+	rows, err := m.DB.Query(stmt, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -70,18 +608,16 @@ func (m *SnippetModel) Latest() ([]*models.Snippet, error) {
 	snippets := []*models.Snippet{}
 	// iterate
 	for rows.Next() {
-		// pointer for Snippet struct
-		s := &models.Snippet{}
 		// copy values from each into Snippet object
-		err = rows.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+		s, err := scanSnippet(rows)
 		if err != nil {
 			return nil, err
 		}
 		snippets = append(snippets, s)
-		// after iteration, check errors with Err()
-		if err = rows.Err(); err != nil {
-			return nil, err
-		}
+	}
+	// after iteration, check errors with Err()
+	if err = rows.Err(); err != nil {
+		return nil, err
 	}
 
 	return snippets, nil
@@ -0,0 +1,163 @@
+package mysql
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"robert-tu.net/snippetbox/pkg/models"
+)
+
+func TestSnippetModelRead(t *testing.T) {
+	// skip test if -short flag
+	if testing.Short() {
+		t.Skip("mysql: skipping integration test")
+	}
+
+	// initialize connection pool
+	db, teardown := newTestDB(t)
+	defer teardown()
+
+	m := SnippetModel{db}
+
+	// each read of snippet 1 uses up one of its two views
+	reads := []struct {
+		name          string
+		id            int
+		wantViewLimit bool
+		wantViewsLeft int
+		wantError     error
+	}{
+		{"First view", 1, true, 1, nil},
+		{"Last view", 1, true, 0, nil},
+		{"Used up", 1, false, 0, models.ErrNoRecord},
+		{"Unlimited", 2, false, 0, nil},
+		{"Unlimited again", 2, false, 0, nil},
+		{"Expired", 3, false, 0, models.ErrNoRecord},
+		{"No views left", 4, false, 0, models.ErrNoRecord},
+		{"Non-existent ID", 99, false, 0, models.ErrNoRecord},
+	}
+
+	for _, tt := range reads {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := m.Read(tt.id)
+
+			if !errors.Is(err, tt.wantError) {
+				t.Fatalf("want %v; got %v", tt.wantError, err)
+			}
+			if err != nil {
+				return
+			}
+
+			if s.ViewLimit != tt.wantViewLimit || s.ViewsLeft != tt.wantViewsLeft {
+				t.Errorf("want view limit %t with %d views left; got %t with %d", tt.wantViewLimit, tt.wantViewsLeft, s.ViewLimit, s.ViewsLeft)
+			}
+			// the last view still shows the content
+			if s.Content == "" {
+				t.Error("want content to be returned")
+			}
+		})
+	}
+}
+
+func TestSnippetModelReadConcurrent(t *testing.T) {
+	// skip test if -short flag
+	if testing.Short() {
+		t.Skip("mysql: skipping integration test")
+	}
+
+	// initialize connection pool
+	db, teardown := newTestDB(t)
+	defer teardown()
+
+	m := SnippetModel{db}
+
+	// snippet 5 has five views left, racing readers must not share one
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	seen := map[int]int{}
+	failed := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s, err := m.Read(5)
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				seen[s.ViewsLeft]++
+			case errors.Is(err, models.ErrNoRecord):
+				failed++
+			default:
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(seen) != 5 || failed != 15 {
+		t.Errorf("want 5 views and 15 refusals; got views %v and %d refusals", seen, failed)
+	}
+	for left, n := range seen {
+		if left < 0 || left > 4 || n != 1 {
+			t.Errorf("want each of 4 to 0 views left seen once; got %d seen %d times", left, n)
+		}
+	}
+}
+
+func TestSnippetModelPurgeExpired(t *testing.T) {
+	// skip test if -short flag
+	if testing.Short() {
+		t.Skip("mysql: skipping integration test")
+	}
+
+	// initialize connection pool
+	db, teardown := newTestDB(t)
+	defer teardown()
+
+	m := SnippetModel{db}
+
+	// the expired snippet and the one without views left, one per batch
+	n, err := m.PurgeExpired(1)
+	if err != nil || n != 1 {
+		t.Fatalf("want %d snippet purged; got %d, %v", 1, n, err)
+	}
+	n, err = m.PurgeExpired(10)
+	if err != nil || n != 1 {
+		t.Fatalf("want %d snippet purged; got %d, %v", 1, n, err)
+	}
+
+	// using up the last view makes a snippet purgeable
+	for i := 0; i < 2; i++ {
+		if _, err = m.Read(1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	n, err = m.PurgeExpired(10)
+	if err != nil || n != 1 {
+		t.Fatalf("want %d snippet purged; got %d, %v", 1, n, err)
+	}
+
+	// purged rows are gone and the rest are kept
+	var ids []int
+	rows, err := db.Query(`SELECT id FROM snippets ORDER BY id`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int
+		if err = rows.Scan(&id); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	if err = rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 2 || ids[0] != 2 || ids[1] != 5 {
+		t.Errorf("want snippets %v left; got %v", []int{2, 5}, ids)
+	}
+}
@@ -0,0 +1,75 @@
+package mysql
+
+import (
+	"database/sql"
+
+	"robert-tu.net/snippetbox/pkg/models"
+)
+
+// define StarModel which wraps sql.DB
+type StarModel struct {
+	DB *sql.DB
+}
+
+// Star marks a snippet as starred by a user, keeping its current title
+// starring a snippet twice has no effect
+func (m *StarModel) Star(userID, snippetID int, title string) error {
+	stmt := `INSERT IGNORE INTO stars (user_id, snippet_id, title, created)
+			VALUES (?, ?, ?, UTC_TIMESTAMP())`
+
+	_, err := m.DB.Exec(stmt, userID, snippetID, title)
+	return err
+}
+
+// Unstar removes the star of a user from a snippet
+func (m *StarModel) Unstar(userID, snippetID int) error {
+	_, err := m.DB.Exec(`DELETE FROM stars WHERE user_id = ? AND snippet_id = ?`, userID, snippetID)
+	return err
+}
+
+// Starred reports whether a user starred a snippet
+func (m *StarModel) Starred(userID, snippetID int) (bool, error) {
+	var starred bool
+	stmt := `SELECT EXISTS(SELECT true FROM stars WHERE user_id = ? AND snippet_id = ?)`
+	err := m.DB.QueryRow(stmt, userID, snippetID).Scan(&starred)
+	return starred, err
+}
+
+// ByUser returns the stars of a user, newest first
+// snippets the user can no longer open are left out of Star.Snippet
+func (m *StarModel) ByUser(userID int) ([]*models.Star, error) {
+	stmt := `SELECT st.snippet_id, st.title, st.created, s.id IS NOT NULL,
+			COALESCE(s.title, ''), COALESCE(s.created, st.created), COALESCE(u.name, ''), COALESCE(s.visibility, ''), COALESCE(s.slug, '')
+			FROM stars st
+			LEFT JOIN snippets s ON s.id = st.snippet_id AND ` + available + ` AND (s.visibility <> 'private' OR s.user_id = st.user_id)
+			LEFT JOIN users u ON u.id = s.user_id
+			WHERE st.user_id = ?
+			ORDER BY st.created DESC, st.snippet_id DESC`
+
+	rows, err := m.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stars := []*models.Star{}
+	for rows.Next() {
+		st := &models.Star{}
+		s := &models.Snippet{}
+		var found bool
+		err = rows.Scan(&st.SnippetID, &st.Title, &st.Created, &found, &s.Title, &s.Created, &s.Owner, &s.Visibility, &s.Slug)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			s.ID = st.SnippetID
+			st.Snippet = s
+		}
+		stars = append(stars, st)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return stars, nil
+}
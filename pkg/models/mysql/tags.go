@@ -0,0 +1,97 @@
+package mysql
+
+import (
+	"database/sql"
+
+	"robert-tu.net/snippetbox/pkg/models"
+)
+
+// define TagModel which wraps sql.DB
+type TagModel struct {
+	DB *sql.DB
+}
+
+// setTags replaces the tags of a snippet within tx, creating tags that don't exist yet
+func setTags(tx *sql.Tx, snippetID int, names []string) error {
+	_, err := tx.Exec(`DELETE FROM snippet_tags WHERE snippet_id = ?`, snippetID)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		// ignore duplicate key errors for existing tags
+		_, err = tx.Exec(`INSERT IGNORE INTO tags (name) VALUES (?)`, name)
+		if err != nil {
+			return err
+		}
+
+		stmt := `INSERT INTO snippet_tags (snippet_id, tag_id)
+				SELECT ?, id FROM tags WHERE name = ?`
+		_, err = tx.Exec(stmt, snippetID, name)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ForSnippet returns the tag names of a snippet in alphabetical order
+func (m *TagModel) ForSnippet(snippetID int) ([]string, error) {
+	stmt := `SELECT t.name FROM tags t
+			INNER JOIN snippet_tags st ON st.tag_id = t.id
+			WHERE st.snippet_id = ?
+			ORDER BY t.name`
+
+	rows, err := m.DB.Query(stmt, snippetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := []string{}
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return names, nil
+}
+
+// Cloud returns up to limit of the most used tags on public snippets, sorted by name
+func (m *TagModel) Cloud(limit int) ([]*models.Tag, error) {
+	stmt := `SELECT name, count FROM (
+				SELECT t.name, COUNT(*) AS count FROM tags t
+				INNER JOIN snippet_tags st ON st.tag_id = t.id
+				INNER JOIN snippets s ON s.id = st.snippet_id
+				WHERE ` + available + ` AND s.visibility = 'public'
+				GROUP BY t.id, t.name
+				ORDER BY count DESC LIMIT ?
+			) popular ORDER BY name`
+
+	rows, err := m.DB.Query(stmt, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []*models.Tag{}
+	for rows.Next() {
+		t := &models.Tag{}
+		if err = rows.Scan(&t.Name, &t.Count); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
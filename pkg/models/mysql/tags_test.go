@@ -0,0 +1,45 @@
+package mysql
+
+import (
+	"reflect"
+	"testing"
+
+	"robert-tu.net/snippetbox/pkg/models"
+)
+
+func TestSnippetModelTags(t *testing.T) {
+	// skip test if -short flag
+	if testing.Short() {
+		t.Skip("mysql: skipping integration test")
+	}
+
+	// initialize connection pool
+	db, teardown := newTestDB(t)
+	defer teardown()
+
+	m := SnippetModel{db}
+	tags := TagModel{db}
+
+	// tags are saved with the snippet
+	s := &models.Snippet{UserID: 1, Title: "Tagged", Content: "...", Language: "plaintext", Visibility: models.Public, Tags: []string{"haiku", "poetry"}}
+	id, err := m.Insert(s, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := tags.ForSnippet(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, s.Tags) {
+		t.Errorf("want tags %v; got %v", s.Tags, got)
+	}
+
+	// and replaced along with the new version
+	err = m.Update(id, 1, 1, "Tagged", "...", "plaintext", models.Public, []string{"nature"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ = tags.ForSnippet(id); !reflect.DeepEqual(got, []string{"nature"}) {
+		t.Errorf("want tags %v; got %v", []string{"nature"}, got)
+	}
+}
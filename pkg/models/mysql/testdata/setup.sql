@@ -1,23 +1,162 @@
+CREATE TABLE users (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    name VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    hashed_password CHAR(60) NOT NULL,
+    created DATETIME NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT TRUE
+);
+
+ALTER TABLE users ADD CONSTRAINT users_uc_email UNIQUE (email);
+
 CREATE TABLE snippets (
     id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    user_id INTEGER NOT NULL,
     title VARCHAR(100) NOT NULL,
     content TEXT NOT NULL,
+    language VARCHAR(32) NOT NULL DEFAULT 'plaintext',
+    filename VARCHAR(255) NOT NULL DEFAULT '',
+    visibility ENUM('public', 'unlisted', 'private') NOT NULL DEFAULT 'public',
+    slug CHAR(22) NOT NULL,
+    hashed_passphrase CHAR(60) NULL,
+    views_left INTEGER NULL,
+    parent_id INTEGER NULL,
     created DATETIME NOT NULL,
-    expires DATETIME NOT NULL
+    expires DATETIME NULL,
+    version INTEGER NOT NULL DEFAULT 1,
+    deleted_at DATETIME NULL
 );
 
 CREATE INDEX idx_snippets_created ON snippets(created);
 
-CREATE TABLE users (
+CREATE INDEX idx_snippets_expires ON snippets(expires);
+
+ALTER TABLE snippets ADD CONSTRAINT snippets_uc_slug UNIQUE (slug);
+
+CREATE FULLTEXT INDEX idx_snippets_fulltext ON snippets(title, content);
+
+ALTER TABLE snippets ADD CONSTRAINT fk_snippets_user FOREIGN KEY (user_id) REFERENCES users(id);
+
+ALTER TABLE snippets ADD CONSTRAINT fk_snippets_parent FOREIGN KEY (parent_id) REFERENCES snippets(id) ON DELETE SET NULL;
+
+CREATE TABLE snippet_revisions (
     id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    snippet_id INTEGER NOT NULL,
+    version INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    title VARCHAR(100) NOT NULL,
+    content TEXT NOT NULL,
+    created DATETIME NOT NULL
+);
+
+ALTER TABLE snippet_revisions ADD CONSTRAINT snippet_revisions_uc_version UNIQUE (snippet_id, version);
+
+ALTER TABLE snippet_revisions ADD CONSTRAINT fk_snippet_revisions_snippet FOREIGN KEY (snippet_id) REFERENCES snippets(id) ON DELETE CASCADE;
+
+ALTER TABLE snippet_revisions ADD CONSTRAINT fk_snippet_revisions_user FOREIGN KEY (user_id) REFERENCES users(id);
+
+CREATE TABLE snippet_files (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    snippet_id INTEGER NOT NULL,
+    position INTEGER NOT NULL,
     name VARCHAR(255) NOT NULL,
-    email VARCHAR(255) NOT NULL,
-    hashed_password CHAR(60) NOT NULL,
+    language VARCHAR(32) NOT NULL DEFAULT 'plaintext',
+    content TEXT NOT NULL
+);
+
+ALTER TABLE snippet_files ADD CONSTRAINT snippet_files_uc_name UNIQUE (snippet_id, name);
+
+ALTER TABLE snippet_files ADD CONSTRAINT fk_snippet_files_snippet FOREIGN KEY (snippet_id) REFERENCES snippets(id) ON DELETE CASCADE;
+
+-- rows are kept when their snippet is purged so the janitor can remove the blobs too
+CREATE TABLE snippet_attachments (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    snippet_id INTEGER NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    content_type VARCHAR(255) NOT NULL,
+    size BIGINT NOT NULL,
+    blob_key VARCHAR(255) NOT NULL,
+    created DATETIME NOT NULL
+);
+
+CREATE INDEX idx_snippet_attachments_snippet ON snippet_attachments(snippet_id);
+
+CREATE TABLE comments (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    snippet_id INTEGER NOT NULL,
+    parent_id INTEGER NULL,
+    user_id INTEGER NOT NULL,
+    content TEXT NOT NULL,
     created DATETIME NOT NULL,
-    active BOOLEAN NOT NULL DEFAULT TRUE
+    updated DATETIME NULL,
+    deleted BOOLEAN NOT NULL DEFAULT FALSE
 );
 
-ALTER TABLE users ADD CONSTRAINT users_uc_email UNIQUE (email);
+CREATE INDEX idx_comments_snippet ON comments(snippet_id, created);
+
+ALTER TABLE comments ADD CONSTRAINT fk_comments_snippet FOREIGN KEY (snippet_id) REFERENCES snippets(id) ON DELETE CASCADE;
+
+ALTER TABLE comments ADD CONSTRAINT fk_comments_parent FOREIGN KEY (parent_id) REFERENCES comments(id) ON DELETE CASCADE;
+
+ALTER TABLE comments ADD CONSTRAINT fk_comments_user FOREIGN KEY (user_id) REFERENCES users(id);
+
+-- snippet_id has no foreign key so stars outlive purged snippets
+CREATE TABLE stars (
+    user_id INTEGER NOT NULL,
+    snippet_id INTEGER NOT NULL,
+    title VARCHAR(100) NOT NULL,
+    created DATETIME NOT NULL,
+    PRIMARY KEY (user_id, snippet_id)
+);
+
+CREATE INDEX idx_stars_snippet ON stars(snippet_id, created);
+
+ALTER TABLE stars ADD CONSTRAINT fk_stars_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+
+CREATE TABLE snippet_views (
+    snippet_id INTEGER NOT NULL,
+    day DATE NOT NULL,
+    views INTEGER NOT NULL,
+    PRIMARY KEY (snippet_id, day)
+);
+
+ALTER TABLE snippet_views ADD CONSTRAINT fk_snippet_views_snippet FOREIGN KEY (snippet_id) REFERENCES snippets(id) ON DELETE CASCADE;
+
+-- hashes of the visitors of each day, for counting unique views
+CREATE TABLE snippet_visitors (
+    snippet_id INTEGER NOT NULL,
+    day DATE NOT NULL,
+    visitor CHAR(32) NOT NULL,
+    PRIMARY KEY (snippet_id, day, visitor)
+);
+
+ALTER TABLE snippet_visitors ADD CONSTRAINT fk_snippet_visitors_snippet FOREIGN KEY (snippet_id) REFERENCES snippets(id) ON DELETE CASCADE;
+
+CREATE TABLE snippet_referrers (
+    snippet_id INTEGER NOT NULL,
+    referrer VARCHAR(255) NOT NULL,
+    views INTEGER NOT NULL,
+    PRIMARY KEY (snippet_id, referrer)
+);
+
+ALTER TABLE snippet_referrers ADD CONSTRAINT fk_snippet_referrers_snippet FOREIGN KEY (snippet_id) REFERENCES snippets(id) ON DELETE CASCADE;
+
+CREATE TABLE tags (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    name VARCHAR(32) NOT NULL
+);
+
+ALTER TABLE tags ADD CONSTRAINT tags_uc_name UNIQUE (name);
+
+CREATE TABLE snippet_tags (
+    snippet_id INTEGER NOT NULL,
+    tag_id INTEGER NOT NULL,
+    PRIMARY KEY (snippet_id, tag_id)
+);
+
+ALTER TABLE snippet_tags ADD CONSTRAINT fk_snippet_tags_snippet FOREIGN KEY (snippet_id) REFERENCES snippets(id) ON DELETE CASCADE;
+
+ALTER TABLE snippet_tags ADD CONSTRAINT fk_snippet_tags_tag FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE;
 
 INSERT INTO users (name, email, hashed_password, created) 
 VALUES (
@@ -25,4 +164,14 @@ VALUES (
     'bob@gmail.com',
     '$2a$12$NuTjWXm3KKntReFwyBVHyuf/to.HEwTy.eS206TNfkGfr6HzGJSWG',
     '2020-12-31 11:00:00'
-);
\ No newline at end of file
+);
+
+-- a view-limited snippet, an unlimited one, an expired one, a used up one
+-- and one read concurrently
+INSERT INTO snippets (user_id, title, content, slug, views_left, created, expires)
+VALUES
+    (1, 'Burn after reading', 'Read me twice', 'YnVybi1hZnRlci1yZWFkaQ', 2, '2021-01-01 10:00:00', NULL),
+    (1, 'An old silent pond', 'A frog jumps into the pond', 'YW4tb2xkLXNpbGVudC1wbw', NULL, '2021-01-01 10:00:00', '2099-01-01 10:00:00'),
+    (1, 'Over the wintry forest', 'Winds howl in rage', 'b3Zlci10aGUtd2ludHJ5LQ', NULL, '2021-01-01 10:00:00', '2021-01-02 10:00:00'),
+    (1, 'Lightning flash', 'What I thought were faces', 'bGlnaHRuaW5nLWZsYXNoLQ', 0, '2021-01-01 10:00:00', NULL),
+    (1, 'The temple bell stops', 'But the sound keeps coming', 'dGhlLXRlbXBsZS1iZWxsLQ', 5, '2021-01-01 10:00:00', NULL);
@@ -1,3 +1,23 @@
-DROP TABLE users;
+DROP TABLE snippet_tags;
 
-DROP TABLE snippets;
\ No newline at end of file
+DROP TABLE tags;
+
+DROP TABLE snippet_referrers;
+
+DROP TABLE snippet_visitors;
+
+DROP TABLE snippet_views;
+
+DROP TABLE stars;
+
+DROP TABLE comments;
+
+DROP TABLE snippet_attachments;
+
+DROP TABLE snippet_files;
+
+DROP TABLE snippet_revisions;
+
+DROP TABLE snippets;
+
+DROP TABLE users;
\ No newline at end of file
@@ -0,0 +1,124 @@
+package mysql
+
+import (
+	"database/sql"
+	"time"
+
+	"robert-tu.net/snippetbox/pkg/models"
+)
+
+// define ViewModel which wraps sql.DB
+type ViewModel struct {
+	DB *sql.DB
+}
+
+// Add saves a batch of views in one transaction
+// views of snippets purged since they were counted are skipped
+func (m *ViewModel) Add(batch *models.ViewBatch) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// selecting from snippets avoids foreign key errors for purged snippets
+	stmt := `INSERT INTO snippet_views (snippet_id, day, views)
+			SELECT id, ?, ? FROM snippets WHERE id = ?
+			ON DUPLICATE KEY UPDATE views = views + ?`
+	for _, v := range batch.Views {
+		_, err = tx.Exec(stmt, v.Day.UTC(), v.Views, v.SnippetID, v.Views)
+		if err != nil {
+			return err
+		}
+	}
+
+	stmt = `INSERT IGNORE INTO snippet_visitors (snippet_id, day, visitor)
+			SELECT id, ?, ? FROM snippets WHERE id = ?`
+	for _, v := range batch.Visitors {
+		_, err = tx.Exec(stmt, v.Day.UTC(), v.Hash, v.SnippetID)
+		if err != nil {
+			return err
+		}
+	}
+
+	stmt = `INSERT INTO snippet_referrers (snippet_id, referrer, views)
+			SELECT id, ?, ? FROM snippets WHERE id = ?
+			ON DUPLICATE KEY UPDATE views = views + ?`
+	for _, r := range batch.Referrers {
+		_, err = tx.Exec(stmt, r.URL, r.Views, r.SnippetID, r.Views)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Daily returns the views of a snippet on each day since the given time, oldest first
+// days without views are left out
+func (m *ViewModel) Daily(snippetID int, since time.Time) ([]*models.DailyViews, error) {
+	stmt := `SELECT v.snippet_id, v.day, v.views,
+			(SELECT COUNT(*) FROM snippet_visitors u WHERE u.snippet_id = v.snippet_id AND u.day = v.day)
+			FROM snippet_views v
+			WHERE v.snippet_id = ? AND v.day >= DATE(?)
+			ORDER BY v.day`
+
+	rows, err := m.DB.Query(stmt, snippetID, since.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	days := []*models.DailyViews{}
+	for rows.Next() {
+		d := &models.DailyViews{}
+		if err = rows.Scan(&d.SnippetID, &d.Day, &d.Views, &d.Unique); err != nil {
+			return nil, err
+		}
+		days = append(days, d)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return days, nil
+}
+
+// Totals returns the views of a snippet ever recorded and the sum of its daily unique visitors
+func (m *ViewModel) Totals(snippetID int) (int, int, error) {
+	stmt := `SELECT
+			(SELECT COALESCE(SUM(views), 0) FROM snippet_views WHERE snippet_id = ?),
+			(SELECT COUNT(*) FROM snippet_visitors WHERE snippet_id = ?)`
+
+	var views, unique int
+	err := m.DB.QueryRow(stmt, snippetID, snippetID).Scan(&views, &unique)
+	return views, unique, err
+}
+
+// Referrers returns up to limit pages sending the most views to a snippet
+func (m *ViewModel) Referrers(snippetID, limit int) ([]*models.Referrer, error) {
+	stmt := `SELECT snippet_id, referrer, views FROM snippet_referrers
+			WHERE snippet_id = ?
+			ORDER BY views DESC, referrer
+			LIMIT ?`
+
+	rows, err := m.DB.Query(stmt, snippetID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	referrers := []*models.Referrer{}
+	for rows.Next() {
+		r := &models.Referrer{}
+		if err = rows.Scan(&r.SnippetID, &r.URL, &r.Views); err != nil {
+			return nil, err
+		}
+		referrers = append(referrers, r)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return referrers, nil
+}
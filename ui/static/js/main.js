@@ -5,4 +5,20 @@ for (var i = 0; i < navLinks.length; i++) {
 		link.classList.add("live");
 		break;
 	}
-}
\ No newline at end of file
+}
+// highlight line ranges linked as #L10-L20, the server handles ?lines=10-20
+var lineRange = window.location.hash.match(/^#L(\d+)(?:-L(\d+))?$/);
+if (lineRange) {
+	var first = parseInt(lineRange[1], 10);
+	var last = lineRange[2] ? parseInt(lineRange[2], 10) : first;
+	for (var n = first; n <= last; n++) {
+		var row = document.getElementById("L" + n);
+		if (row) {
+			row.classList.add("selected");
+		}
+	}
+	var firstRow = document.getElementById("L" + first);
+	if (firstRow) {
+		firstRow.scrollIntoView();
+	}
+}